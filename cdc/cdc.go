@@ -0,0 +1,206 @@
+// Package cdc implements FastCDC style content-defined chunking with per-chunk XXH64 digests.
+//
+// Chunk boundaries are picked with a Gear rolling hash, so inserting or removing bytes only
+// changes the chunks around the edit, which makes it suitable for deduplication.
+// The Gear table is derived from xxhash.Checksum64S, boundaries and digests are identical
+// across runs, platforms and the sizes of the underlying reads.
+package cdc
+
+import (
+	"errors"
+	"io"
+	"math/bits"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+const (
+	// DefaultMinSize is the minimum chunk size used when Options.MinSize is 0.
+	DefaultMinSize = 2 << 10
+	// DefaultAvgSize is the average chunk size used when Options.AvgSize is 0.
+	DefaultAvgSize = 8 << 10
+	// DefaultMaxSize is the maximum chunk size used when Options.MaxSize is 0.
+	DefaultMaxSize = 64 << 10
+
+	minSize = 64
+	maxSize = 1 << 30
+
+	// normalization level, the mask is made stricter by this many bits before the
+	// average size and looser after it, which narrows the chunk size distribution.
+	normLevel = 2
+)
+
+// ErrInvalidOptions is returned by NewChunker when the sizes are out of range.
+var ErrInvalidOptions = errors.New("cdc: invalid options")
+
+var gear = func() (t [256]uint64) {
+	for i := range t {
+		t[i] = xxhash.Checksum64S([]byte{byte(i)}, 0)
+	}
+	return
+}()
+
+// Options configures a Chunker, zero values select the defaults.
+type Options struct {
+	// MinSize, AvgSize and MaxSize must satisfy 64 <= MinSize <= AvgSize <= MaxSize <= 1GiB.
+	// AvgSize is rounded down to a power of two, which must still be at least MinSize.
+	MinSize, AvgSize, MaxSize int
+
+	// Seed is used for the chunk digests, it does not affect the boundaries.
+	Seed uint64
+}
+
+// Chunk is a chunk returned by Chunker.
+type Chunk struct {
+	// Offset is the position of the chunk in the input stream.
+	Offset int64
+	// Length is the size of the chunk.
+	Length int
+	// Sum64 is the XXH64 digest of the chunk.
+	Sum64 uint64
+	// Data is the content of the chunk, it is only valid until the next call to Next.
+	Data []byte
+}
+
+// Chunker splits an io.Reader into content-defined chunks.
+//
+//	c, err := cdc.NewChunker(r, cdc.Options{})
+//	for c.Next() {
+//		ch := c.Chunk()
+//		...
+//	}
+//	if err := c.Err(); err != nil { ... }
+type Chunker struct {
+	r    io.Reader
+	opts Options
+
+	maskS, maskL uint64
+
+	buf        []byte
+	start, end int
+	eof        bool
+	err        error
+
+	off   int64
+	chunk Chunk
+}
+
+// NewChunker returns a Chunker that reads from r.
+func NewChunker(r io.Reader, opts Options) (*Chunker, error) {
+	if opts.MinSize == 0 {
+		opts.MinSize = DefaultMinSize
+	}
+	if opts.AvgSize == 0 {
+		opts.AvgSize = DefaultAvgSize
+	}
+	if opts.MaxSize == 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+
+	if opts.MinSize < minSize || opts.MinSize > opts.AvgSize || opts.AvgSize > opts.MaxSize || opts.MaxSize > maxSize {
+		return nil, ErrInvalidOptions
+	}
+
+	avgBits := uint(bits.Len(uint(opts.AvgSize)) - 1)
+	if opts.AvgSize = 1 << avgBits; opts.AvgSize < opts.MinSize {
+		return nil, ErrInvalidOptions
+	}
+
+	return &Chunker{
+		r:     r,
+		opts:  opts,
+		maskS: topBits(avgBits + normLevel),
+		maskL: topBits(avgBits - normLevel),
+		buf:   make([]byte, 2*opts.MaxSize),
+	}, nil
+}
+
+// Next advances to the next chunk, it returns false at the end of the input or on error.
+func (c *Chunker) Next() bool {
+	if c.err != nil {
+		return false
+	}
+
+	if c.end-c.start < c.opts.MaxSize && !c.eof {
+		c.fill()
+		if c.err != nil {
+			return false
+		}
+	}
+
+	if c.start == c.end {
+		return false
+	}
+
+	data := c.buf[c.start:c.end]
+	n := cut(data, c.opts.MinSize, c.opts.AvgSize, c.opts.MaxSize, c.maskS, c.maskL)
+	data = data[:n:n]
+
+	c.chunk = Chunk{
+		Offset: c.off,
+		Length: n,
+		Sum64:  xxhash.Checksum64S(data, c.opts.Seed),
+		Data:   data,
+	}
+	c.start += n
+	c.off += int64(n)
+	return true
+}
+
+// Chunk returns the current chunk.
+func (c *Chunker) Chunk() Chunk { return c.chunk }
+
+// Err returns the first non-EOF error that was encountered.
+func (c *Chunker) Err() error { return c.err }
+
+// fill moves the unread data to the front of the buffer and reads until it holds MaxSize bytes or EOF.
+func (c *Chunker) fill() {
+	c.end = copy(c.buf, c.buf[c.start:c.end])
+	c.start = 0
+
+	for c.end < c.opts.MaxSize {
+		n, err := c.r.Read(c.buf[c.end:])
+		c.end += n
+		if err == io.EOF {
+			c.eof = true
+			return
+		}
+		if err != nil {
+			c.err = err
+			return
+		}
+	}
+}
+
+// cut returns the length of the next chunk in data.
+func cut(data []byte, min, avg, max int, maskS, maskL uint64) int {
+	n := len(data)
+	if n <= min {
+		return n
+	}
+	if n > max {
+		n = max
+	}
+	if avg > n {
+		avg = n
+	}
+
+	var h uint64
+	for i, b := range data[min:avg] {
+		h = h<<1 + gear[b]
+		if h&maskS == 0 {
+			return min + i + 1
+		}
+	}
+	for i, b := range data[avg:n] {
+		h = h<<1 + gear[b]
+		if h&maskL == 0 {
+			return avg + i + 1
+		}
+	}
+	return n
+}
+
+func topBits(n uint) uint64 {
+	return ^uint64(0) << (64 - n)
+}
@@ -0,0 +1,166 @@
+package cdc_test
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+
+	"github.com/OneOfOne/xxhash"
+	"github.com/OneOfOne/xxhash/cdc"
+)
+
+func randomData(n int, seed int64) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(b)
+	return b
+}
+
+func chunks(t testing.TB, r io.Reader, opts cdc.Options) []cdc.Chunk {
+	c, err := cdc.NewChunker(r, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []cdc.Chunk
+	for c.Next() {
+		ch := c.Chunk()
+		ch.Data = append([]byte(nil), ch.Data...)
+		out = append(out, ch)
+	}
+	if err := c.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestChunks(t *testing.T) {
+	var (
+		data = randomData(1<<20, 1)
+		opts = cdc.Options{MinSize: 1 << 10, AvgSize: 4 << 10, MaxSize: 16 << 10, Seed: 9}
+		all  = chunks(t, bytes.NewReader(data), opts)
+		buf  bytes.Buffer
+		off  int64
+	)
+	for i, ch := range all {
+		if ch.Offset != off || ch.Length != len(ch.Data) {
+			t.Fatalf("chunk %d: bad offset/length %d/%d", i, ch.Offset, ch.Length)
+		}
+		if ch.Length > opts.MaxSize || (ch.Length < opts.MinSize && i != len(all)-1) {
+			t.Fatalf("chunk %d: size %d out of range", i, ch.Length)
+		}
+		if ch.Sum64 != xxhash.Checksum64S(ch.Data, opts.Seed) {
+			t.Fatalf("chunk %d: bad digest", i)
+		}
+		buf.Write(ch.Data)
+		off += int64(ch.Length)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("chunks don't add up to the input")
+	}
+	if avg := len(data) / len(all); avg < 3<<10 || avg > 6<<10 {
+		t.Fatalf("unexpected average chunk size %d", avg)
+	}
+}
+
+func TestReadSizes(t *testing.T) {
+	data := randomData(256<<10, 2)
+	want := chunks(t, bytes.NewReader(data), cdc.Options{})
+	for name, r := range map[string]io.Reader{
+		"OneByte": iotest.OneByteReader(bytes.NewReader(data)),
+		"Half":    iotest.HalfReader(bytes.NewReader(data)),
+		"DataErr": iotest.DataErrReader(bytes.NewReader(data)),
+	} {
+		got := chunks(t, r, cdc.Options{})
+		if len(got) != len(want) {
+			t.Fatalf("%s: expected %d chunks, got %d", name, len(want), len(got))
+		}
+		for i := range got {
+			if got[i].Sum64 != want[i].Sum64 || got[i].Length != want[i].Length {
+				t.Fatalf("%s: chunk %d differs", name, i)
+			}
+		}
+	}
+}
+
+func TestShiftResistance(t *testing.T) {
+	data := randomData(512<<10, 3)
+	edited := append([]byte("a few inserted bytes"), data...)
+
+	seen := make(map[uint64]bool)
+	for _, ch := range chunks(t, bytes.NewReader(data), cdc.Options{}) {
+		seen[ch.Sum64] = true
+	}
+	all := chunks(t, bytes.NewReader(edited), cdc.Options{})
+	var shared int
+	for _, ch := range all {
+		if seen[ch.Sum64] {
+			shared++
+		}
+	}
+	if shared < len(all)-2 {
+		t.Fatalf("only %d of %d chunks survived a prefix insert", shared, len(all))
+	}
+}
+
+// TestGolden guards against changes to the boundaries or digests, which must be stable across releases and platforms.
+func TestGolden(t *testing.T) {
+	got := chunks(t, bytes.NewReader(randomData(64<<10, 4)), cdc.Options{MinSize: 1 << 10, AvgSize: 4 << 10, MaxSize: 16 << 10})
+	want := []struct {
+		length int
+		sum    uint64
+	}{
+		{4907, 0x2909569341952c5d},
+		{5018, 0x6ad7dce25864a273},
+		{4661, 0x995449a687313d61},
+		{3461, 0x009713a9997deea0},
+		{6179, 0x648260ad8ed20a9a},
+		{5159, 0xe333e695babb4795},
+		{1628, 0x373a768e648453d4},
+		{1264, 0x086bba7b5220370a},
+		{4163, 0xa8452e7ae0a7c42d},
+		{4432, 0x36307f6dbed4cb8c},
+		{5275, 0x4d0f9a9089b6ea9c},
+		{4584, 0x29e995242e9f2ebd},
+		{4482, 0xbbb0af27b7e18a55},
+		{4330, 0xd6a0fb7fb094d773},
+		{5993, 0x71648668fe053abb},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d chunks, got %d", len(want), len(got))
+	}
+	for i, w := range want {
+		if got[i].Length != w.length || got[i].Sum64 != w.sum {
+			t.Errorf("chunk %d: expected {%d, 0x%x}, got {%d, 0x%x}", i, w.length, w.sum, got[i].Length, got[i].Sum64)
+		}
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	for _, o := range []cdc.Options{{MinSize: 32}, {MinSize: 4096, AvgSize: 2048}, {AvgSize: 1 << 20, MaxSize: 1 << 19}, {MinSize: 3000, AvgSize: 3000}} {
+		if _, err := cdc.NewChunker(nil, o); err != cdc.ErrInvalidOptions {
+			t.Fatalf("%+v: expected ErrInvalidOptions, got %v", o, err)
+		}
+	}
+}
+
+func BenchmarkChunker(b *testing.B) {
+	data := randomData(16<<20, 5)
+	b.Run("CDC", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			c, _ := cdc.NewChunker(bytes.NewReader(data), cdc.Options{})
+			for c.Next() {
+			}
+		}
+	})
+	b.Run("XXHash64.Write", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		h := xxhash.New64()
+		for i := 0; i < b.N; i++ {
+			h.Reset()
+			io.Copy(h, bytes.NewReader(data))
+			h.Sum64()
+		}
+	})
+}
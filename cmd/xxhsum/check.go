@@ -0,0 +1,158 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strconv"
+)
+
+// checkStats counts the outcome of every line of a checksum file.
+type checkStats struct {
+	ok         int
+	mismatched int
+	unreadable int
+	malformed  int
+	missing    int // only counted with --ignore-missing
+}
+
+// failed reports whether the check should make xxhsum exit non-zero.
+func (st checkStats) failed(strict bool) bool {
+	return st.mismatched > 0 || st.unreadable > 0 || (strict && st.malformed > 0) ||
+		st.ok+st.mismatched+st.unreadable == 0
+}
+
+// check verifies the checksum file fn, printing an OK or FAILED line per file
+// followed by warnings summarizing the failures, like coreutils' sha256sum -c.
+func check(sema *sema, fn string) (st checkStats) {
+	var f *os.File
+	if fn == "-" {
+		f = os.Stdin
+	} else {
+		var err error
+		if f, err = os.Open(fn); err != nil {
+			errorf("error opening %s: %v", displayName(fn), displayError(err))
+			return
+		}
+		defer f.Close()
+	}
+
+	st = verify(sema, f, fn)
+
+	switch {
+	case st.ok+st.mismatched+st.unreadable+st.missing == 0:
+		errorf("%s: no properly formatted checksum lines found", displayName(fn))
+		return
+	case st.ok+st.mismatched+st.unreadable == 0:
+		errorf("%s: no file was verified", displayName(fn))
+		return
+	}
+
+	if !*statusArg {
+		if st.malformed > 0 {
+			warnf("xxhsum: WARNING: %d %s improperly formatted", st.malformed, plural(st.malformed, "line is", "lines are"))
+		}
+		if st.unreadable > 0 {
+			warnf("xxhsum: WARNING: %d listed %s could not be read", st.unreadable, plural(st.unreadable, "file", "files"))
+		}
+		if st.mismatched > 0 {
+			warnf("xxhsum: WARNING: %d computed %s did NOT match", st.mismatched, plural(st.mismatched, "checksum", "checksums"))
+		}
+	}
+	if st.failed(*strictArg) {
+		setErrored()
+	}
+	return
+}
+
+// verify hashes every file listed in r and waits for the results, which are printed in the order they're listed.
+func verify(sema *sema, r io.Reader, fn string) (st checkStats) {
+	o := newOrderer(sema, reorderWindow(), *unorderedArg)
+	malformed, err := scanManifest(r, fn, func(e entry) {
+		o.Run(func() func() {
+			h, n, err := hashEntry(e)
+			return func() { st.add(e, h, n, err) }
+		})
+	})
+	o.Wait()
+
+	st.malformed = malformed
+	if err != nil {
+		errorf("%s: %v", displayName(fn), displayError(err))
+	}
+	return
+}
+
+// scanManifest parses the checksum file r, named fn, and calls visit for every entry.
+// It returns the number of malformed lines, which are reported with --warn.
+func scanManifest(r io.Reader, fn string, visit func(e entry)) (malformed int, err error) {
+	var (
+		p  = newParser(*seedArg)
+		sc = newRecordScanner(r)
+	)
+	p.raw = *zeroArg
+
+	for n := 1; sc.Scan(); n++ {
+		e, ok, err := p.parse(sc.Text())
+		if err != nil {
+			malformed++
+			if (*warnArg || err == errUnsupported) && !*statusArg {
+				warnf("%s: %d: %v", displayName(fn), n, err)
+			}
+		}
+		if ok {
+			visit(e)
+		}
+	}
+	return malformed, sc.Err()
+}
+
+// readManifest returns the entries of the checksum file fn, "-" reads stdin.
+func readManifest(fn string) (entries []entry, malformed int, err error) {
+	f := os.Stdin
+	if fn != "-" {
+		if f, err = os.Open(fn); err != nil {
+			return nil, 0, err
+		}
+		defer f.Close()
+	}
+	malformed, err = scanManifest(f, fn, func(e entry) { entries = append(entries, e) })
+	return
+}
+
+// add counts and writes the result of checking e.
+func (st *checkStats) add(e entry, h uint64, n int64, err error) {
+	r := newRecord(e, n, err)
+	r.Expected = e.hex()
+	if err == nil {
+		actual := e
+		actual.sum = h
+		r.Digest, r.Decimal = actual.hex(), strconv.FormatUint(h, 10)
+	}
+
+	switch {
+	case err != nil && os.IsNotExist(err) && *ignoreMissingArg:
+		st.missing++
+		r.Status = statusMissing
+	case err != nil:
+		st.unreadable++
+		r.Status = statusUnreadable
+	case h != e.sum:
+		st.mismatched++
+		r.Status = statusFailed
+	default:
+		st.ok++
+		r.Status = statusOK
+	}
+
+	if *statusArg || (*quietArg && r.Status == statusOK) {
+		return
+	}
+	out.write(r)
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.txt")
+	bad := filepath.Join(dir, "bad.txt")
+	for _, fn := range []string{good, bad} {
+		if err := os.WriteFile(fn, []byte("hello\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sum := xxhash.ChecksumString64("hello\n")
+
+	manifest := strings.Join([]string{
+		entry{name: good, sum: sum, algo: xxhash.XXH64}.format(false),
+		entry{name: bad, sum: sum + 1, algo: xxhash.XXH64}.format(false),
+		entry{name: filepath.Join(dir, "missing.txt"), sum: sum, algo: xxhash.XXH64}.format(true),
+		"not a checksum line",
+		"# a comment",
+	}, "\n")
+
+	defer func(status, ignore bool) { *statusArg, *ignoreMissingArg = status, ignore }(*statusArg, *ignoreMissingArg)
+	*statusArg = true
+
+	st := verify(newSema(runtime.NumCPU()), strings.NewReader(manifest), "manifest")
+	if want := (checkStats{ok: 1, mismatched: 1, unreadable: 1, malformed: 1}); st != want {
+		t.Fatalf("got %+v; want %+v", st, want)
+	}
+	if !st.failed(false) {
+		t.Fatal("a mismatch should fail the check")
+	}
+
+	*ignoreMissingArg = true
+	st = verify(newSema(runtime.NumCPU()), strings.NewReader(manifest), "manifest")
+	if want := (checkStats{ok: 1, mismatched: 1, malformed: 1, missing: 1}); st != want {
+		t.Fatalf("got %+v; want %+v", st, want)
+	}
+}
+
+func TestVerifyTree(t *testing.T) {
+	root := makeTree(t, "a.txt", ".hidden/b.txt")
+	h, err := treeDigest(newSema(2), &walker{}, root, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := entry{name: root, sum: h, algo: xxhash.XXH64, tree: true}.format(false) + "\n" +
+		entry{name: root, sum: h + 1, algo: xxhash.XXH64, tree: true, mode: true}.format(false) + "\n" +
+		entry{name: filepath.Join(root, "a.txt"), sum: h, algo: xxhash.XXH64, tree: true}.format(false)
+
+	defer func(s bool) { *statusArg = s }(*statusArg)
+	*statusArg = true
+
+	st := verify(newSema(2), strings.NewReader(manifest), "manifest")
+	if want := (checkStats{ok: 1, mismatched: 1, unreadable: 1}); st != want {
+		t.Fatalf("got %+v; want %+v", st, want)
+	}
+}
+
+func TestVerifyAdversarialNames(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("most of these names aren't valid on windows")
+	}
+	dir := t.TempDir()
+	var lines, records []string
+	for i, name := range adversarialNames {
+		fn := filepath.Join(dir, name)
+		if err := os.WriteFile(fn, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		e := entry{name: fn, sum: xxhash.ChecksumString64(name), algo: xxhash.XXH64}
+		lines = append(lines, e.format(i%2 == 0))
+		records = append(records, e.formatRaw(i%2 == 0))
+	}
+
+	defer func(status, zero bool) { *statusArg, *zeroArg = status, zero }(*statusArg, *zeroArg)
+	*statusArg = true
+
+	want := checkStats{ok: len(adversarialNames)}
+	if st := verify(newSema(runtime.NumCPU()), strings.NewReader(strings.Join(lines, "\n")), "manifest"); st != want {
+		t.Fatalf("got %+v; want %+v", st, want)
+	}
+
+	*zeroArg = true
+	if st := verify(newSema(runtime.NumCPU()), strings.NewReader(strings.Join(records, "\x00")+"\x00"), "manifest"); st != want {
+		t.Fatalf("-z: got %+v; want %+v", st, want)
+	}
+}
+
+func TestCheckStatsFailed(t *testing.T) {
+	for _, c := range []struct {
+		st     checkStats
+		strict bool
+		want   bool
+	}{
+		{checkStats{ok: 1}, false, false},
+		{checkStats{ok: 1, malformed: 1}, false, false},
+		{checkStats{ok: 1, malformed: 1}, true, true},
+		{checkStats{ok: 1, unreadable: 1}, false, true},
+		{checkStats{ok: 1, mismatched: 1}, false, true},
+		{checkStats{missing: 1}, false, true},
+		{checkStats{}, false, true},
+	} {
+		if got := c.st.failed(c.strict); got != c.want {
+			t.Errorf("%+v strict=%v: got %v; want %v", c.st, c.strict, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+// digestKey identifies the contents of a file for rename detection.
+type digestKey struct {
+	algo string // algoName, so file and tree digests are told apart
+	seed uint64
+	sum  uint64
+}
+
+func keyOf(e entry) digestKey { return digestKey{e.algoName(), e.seed, e.sum} }
+
+// isEmptySum reports whether e is the checksum of an empty file, which says nothing about which file it was.
+func isEmptySum(e entry) bool {
+	switch {
+	case e.tree:
+		return false
+	case e.algo == xxhash.XXH32:
+		return e.sum == uint64(xxhash.Checksum32S(nil, uint32(e.seed)))
+	}
+	return e.sum == xxhash.Checksum64S(nil, e.seed)
+}
+
+// manifestDiff is the difference between two manifests, every list is sorted by name.
+type manifestDiff struct {
+	added, removed, changed []string
+	renamed                 [][2]string // before, after
+}
+
+func (d *manifestDiff) empty() bool {
+	return len(d.added)+len(d.removed)+len(d.changed)+len(d.renamed) == 0
+}
+
+// diffManifests compares two manifests, a removed and an added file with the same digest are reported as a rename
+// unless they're empty.
+func diffManifests(before, after []entry) *manifestDiff {
+	var (
+		d        manifestDiff
+		beforeBy = make(map[string]entry, len(before))
+		afterBy  = make(map[string]entry, len(after))
+		gone     []entry
+		byHash   = map[digestKey][]string{} // added names by digest
+	)
+	for _, e := range before {
+		beforeBy[e.name] = e
+	}
+	for _, e := range after {
+		afterBy[e.name] = e
+	}
+
+	for _, e := range after {
+		o, ok := beforeBy[e.name]
+		switch {
+		case !ok && isEmptySum(e):
+			d.added = append(d.added, e.name)
+		case !ok:
+			byHash[keyOf(e)] = append(byHash[keyOf(e)], e.name)
+		case keyOf(o) != keyOf(e):
+			d.changed = append(d.changed, e.name)
+		}
+	}
+	for _, e := range before {
+		if _, ok := afterBy[e.name]; !ok {
+			gone = append(gone, e)
+		}
+	}
+
+	// pair renames deterministically, in name order on both sides.
+	sort.Slice(gone, func(i, j int) bool { return gone[i].name < gone[j].name })
+	for _, names := range byHash {
+		sort.Strings(names)
+	}
+	for _, e := range gone {
+		if names := byHash[keyOf(e)]; len(names) > 0 {
+			d.renamed = append(d.renamed, [2]string{e.name, names[0]})
+			byHash[keyOf(e)] = names[1:]
+		} else {
+			d.removed = append(d.removed, e.name)
+		}
+	}
+	for _, names := range byHash {
+		d.added = append(d.added, names...)
+	}
+
+	sort.Strings(d.added)
+	sort.Strings(d.changed)
+	return &d
+}
+
+// write prints one line per file, the kind of change followed by the escaped name.
+func (d *manifestDiff) write(w io.Writer) {
+	for _, n := range d.added {
+		fmt.Fprintf(w, "added    %s\n", displayName(n))
+	}
+	for _, n := range d.removed {
+		fmt.Fprintf(w, "removed  %s\n", displayName(n))
+	}
+	for _, n := range d.changed {
+		fmt.Fprintf(w, "changed  %s\n", displayName(n))
+	}
+	for _, r := range d.renamed {
+		fmt.Fprintf(w, "renamed  %s -> %s\n", displayName(r[0]), displayName(r[1]))
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+func TestDiffManifests(t *testing.T) {
+	e := func(name string, sum uint64) entry { return entry{name: name, sum: sum, algo: xxhash.XXH64} }
+	old := []entry{e("same", 1), e("changed", 2), e("gone", 3), e("moved", 4), e("dup1", 5), e("dup2", 5)}
+	cur := []entry{e("same", 1), e("changed", 20), e("new", 6), e("moved-to", 4), e("dup3", 5), e("dup1", 5)}
+
+	d := diffManifests(old, cur)
+	want := &manifestDiff{
+		added:   []string{"new"},
+		removed: []string{"gone"},
+		changed: []string{"changed"},
+		renamed: [][2]string{{"dup2", "dup3"}, {"moved", "moved-to"}},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Fatalf("got %+v; want %+v", d, want)
+	}
+
+	var buf bytes.Buffer
+	d.write(&buf)
+	const out = "added    new\nremoved  gone\nchanged  changed\nrenamed  dup2 -> dup3\nrenamed  moved -> moved-to\n"
+	if buf.String() != out {
+		t.Fatalf("got %q; want %q", buf.String(), out)
+	}
+
+	if d := diffManifests(old, old); !d.empty() {
+		t.Fatalf("expected no differences, got %+v", d)
+	}
+
+	// the same digest under another algorithm isn't a rename.
+	d = diffManifests([]entry{e("a", 1)}, []entry{{name: "b", sum: 1, algo: xxhash.XXH32}})
+	if len(d.renamed) != 0 || len(d.added) != 1 || len(d.removed) != 1 {
+		t.Fatalf("unexpected diff %+v", d)
+	}
+
+	// neither are two empty files.
+	empty := xxhash.Checksum64S(nil, 0)
+	d = diffManifests([]entry{e("a", empty), e("b", empty)}, []entry{e("c", empty), e("d", empty)})
+	if want := (&manifestDiff{added: []string{"c", "d"}, removed: []string{"a", "b"}}); !reflect.DeepEqual(d, want) {
+		t.Fatalf("got %+v; want %+v", d, want)
+	}
+}
+
+func TestDiffRefusesMalformed(t *testing.T) {
+	dir := t.TempDir()
+	good, bad := filepath.Join(dir, "good.xx"), filepath.Join(dir, "bad.xx")
+	ln := entry{name: "a", sum: 1, algo: xxhash.XXH64}.format(false) + "\n"
+	if err := os.WriteFile(good, []byte(ln), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bad, []byte(ln+"garbage\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(e bool) { errored = e }(errored)
+	if code := runCommand("diff", []string{good, good}); code != 0 {
+		t.Fatalf("expected 0 for identical manifests, got %d", code)
+	}
+	if code := runCommand("diff", []string{good, bad}); code != 2 {
+		t.Fatalf("expected 2 for a malformed manifest, got %d", code)
+	}
+}
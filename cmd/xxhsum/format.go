@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+// entry is a single checksum line.
+type entry struct {
+	name string
+	sum  uint64
+	algo xxhash.Algorithm
+	seed uint64
+	tag  bool // parsed from a BSD style line
+	tree bool // a --tree digest of the directory name, always written in the BSD style
+	mode bool // the tree digest includes permission bits, --tree-modes
+
+	legacy bool // parsed from a legacy line, its seed and algorithm came from the headers
+}
+
+// tags of --tree lines, which -c verifies by computing the tree digest again.
+const (
+	treeTag      = "XXH64-TREE"
+	treeModesTag = "XXH64-TREE-MODES"
+)
+
+// algoName returns the tag of e's BSD style line.
+func (e entry) algoName() string {
+	switch {
+	case e.tree && e.mode:
+		return treeModesTag
+	case e.tree:
+		return treeTag
+	}
+	return e.algo.String()
+}
+
+// format returns e in the GNU style used by upstream xxhsum, `hex  name`,
+// or in the BSD style, `XXH64 (name) = hex`, if tag is set or e is a --tree digest.
+// Like coreutils, a name with backslashes, newlines or carriage returns is escaped and the line starts with a backslash.
+func (e entry) format(tag bool) string {
+	if name, ok := escapeName(e.name); ok {
+		return `\` + e.formatName(tag, name)
+	}
+	return e.formatName(tag, e.name)
+}
+
+// formatRaw is format without escaping, for NUL terminated records.
+func (e entry) formatRaw(tag bool) string {
+	return e.formatName(tag, e.name)
+}
+
+func (e entry) formatName(tag bool, name string) string {
+	if tag || e.tree {
+		return fmt.Sprintf("%s (%s) = %s", e.algoName(), name, e.hex())
+	}
+	return e.hex() + "  " + name
+}
+
+// formatLegacy returns e in the legacy `decimal<TAB>name` format, which needs `# seed` and `# 32bit` / `# 64bit` headers.
+func (e entry) formatLegacy() string {
+	if e.algo == xxhash.XXH32 {
+		return fmt.Sprintf("%-10d\t%s", e.sum, e.name)
+	}
+	return fmt.Sprintf("%-20d\t%s", e.sum, e.name)
+}
+
+func (e entry) hex() string {
+	return fmt.Sprintf("%0*x", digestLen(e.algo), e.sum)
+}
+
+func digestLen(a xxhash.Algorithm) int {
+	if a == xxhash.XXH32 {
+		return 8
+	}
+	return 16
+}
+
+var (
+	errMalformed   = errors.New("improperly formatted checksum line")
+	errUnsupported = errors.New("XXH3 and XXH128 checksums are not supported")
+)
+
+// parseAlgo parses the names accepted by --algo and used as BSD tags.
+func parseAlgo(s string) (xxhash.Algorithm, error) {
+	switch strings.ToLower(s) {
+	case "xxh32":
+		return xxhash.XXH32, nil
+	case "xxh64":
+		return xxhash.XXH64, nil
+	case "xxh3", "xxh128":
+		return 0, errUnsupported
+	}
+	return 0, fmt.Errorf("unknown algorithm %q", s)
+}
+
+// parser reads checksum lines in the upstream GNU and BSD styles as well as the legacy
+// `# seed N` / `# 64bit` format with decimal sums, which is detected by its headers.
+type parser struct {
+	seed   uint64 // for GNU and BSD lines, which can't record one
+	raw    bool   // NUL terminated records, names aren't escaped and may end with \r
+	legacy bool
+
+	legacySeed uint64
+	legacyAlgo xxhash.Algorithm
+}
+
+func newParser(seed uint64) *parser {
+	return &parser{seed: seed, legacyAlgo: xxhash.XXH64}
+}
+
+// parse returns the entry on ln, ok is false for blank lines, comments and legacy headers.
+func (p *parser) parse(ln string) (e entry, ok bool, err error) {
+	if !p.raw {
+		ln = strings.TrimRight(ln, "\r")
+	}
+	if strings.TrimSpace(ln) == "" {
+		return e, false, nil
+	}
+
+	if ln[0] == '#' {
+		switch h := strings.TrimSpace(ln); {
+		case strings.HasPrefix(h, "# seed "):
+			if p.legacySeed, err = strconv.ParseUint(strings.TrimSpace(h[7:]), 10, 64); err != nil {
+				return e, false, errMalformed
+			}
+			p.legacy = true
+		case h == "# 32bit":
+			p.legacyAlgo, p.legacy = xxhash.XXH32, true
+		case h == "# 64bit":
+			p.legacyAlgo, p.legacy = xxhash.XXH64, true
+		}
+		return e, false, nil
+	}
+
+	if !p.raw && ln[0] == '\\' {
+		if e, ok, err = p.parseLine(ln[1:]); !ok {
+			return e, ok, err
+		}
+		if e.name, ok = unescapeName(e.name); !ok {
+			return entry{}, false, errMalformed
+		}
+		return e, true, nil
+	}
+	return p.parseLine(ln)
+}
+
+func (p *parser) parseLine(ln string) (e entry, ok bool, err error) {
+	if p.legacy {
+		if e, ok = p.parseLegacy(ln); ok {
+			return e, true, nil
+		}
+	}
+	if e, ok, err = p.parseBSD(ln); ok || err != nil {
+		return e, ok, err
+	}
+	if e, ok, err = p.parseGNU(ln); ok || err != nil {
+		return e, ok, err
+	}
+	return e, false, errMalformed
+}
+
+// parseGNU parses `hex  name`, or `hex *name` for files hashed in binary mode.
+// The algorithm is inferred from the digest length, upstream marks XXH3 digests with an `XXH3_` prefix.
+func (p *parser) parseGNU(ln string) (e entry, ok bool, err error) {
+	i := strings.IndexByte(ln, ' ')
+	if i < 0 || i+2 > len(ln) || (ln[i+1] != ' ' && ln[i+1] != '*') {
+		return e, false, nil
+	}
+	if strings.HasPrefix(ln, "XXH3_") || (i == 32 && isHex(ln[:i])) {
+		return e, false, errUnsupported
+	}
+	if e.algo, e.sum, ok = parseHex(ln[:i]); !ok {
+		return e, false, nil
+	}
+	e.name, e.seed = ln[i+2:], p.seed
+	return e, e.name != "", nil
+}
+
+// parseBSD parses `XXH32 (name) = hex` and `XXH64 (name) = hex`, as well as --tree lines.
+func (p *parser) parseBSD(ln string) (e entry, ok bool, err error) {
+	i := strings.Index(ln, " (")
+	j := strings.LastIndex(ln, ") = ")
+	if i < 0 || j < i+2 || !strings.HasPrefix(ln, "XXH") {
+		return e, false, nil
+	}
+
+	algo := xxhash.XXH64
+	switch ln[:i] {
+	case treeTag:
+		e.tree = true
+	case treeModesTag:
+		e.tree, e.mode = true, true
+	default:
+		if algo, err = parseAlgo(ln[:i]); err != nil {
+			if err == errUnsupported {
+				return e, false, err
+			}
+			return e, false, nil
+		}
+	}
+
+	if e.algo, e.sum, ok = parseHex(ln[j+4:]); !ok || e.algo != algo {
+		return e, false, nil
+	}
+	e.name, e.seed, e.tag = ln[i+2:j], p.seed, !e.tree
+	return e, e.name != "", nil
+}
+
+// parseLegacy parses `decimal<TAB>name` as written by older versions of this tool.
+func (p *parser) parseLegacy(ln string) (e entry, ok bool) {
+	parts := strings.SplitN(ln, "\t", 2)
+	if len(parts) != 2 {
+		return e, false
+	}
+	// the sum is padded, the name is written as is.
+	sum, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, digestLen(p.legacyAlgo)*4)
+	if err != nil {
+		return e, false
+	}
+	e = entry{name: parts[1], sum: sum, algo: p.legacyAlgo, seed: p.legacySeed, legacy: true}
+	return e, e.name != ""
+}
+
+// parseHex parses a lowercase or uppercase hex digest, the digest length selects the algorithm.
+func parseHex(s string) (algo xxhash.Algorithm, sum uint64, ok bool) {
+	switch len(s) {
+	case 8:
+		algo = xxhash.XXH32
+	case 16:
+		algo = xxhash.XXH64
+	default:
+		return 0, 0, false
+	}
+	sum, err := strconv.ParseUint(s, 16, len(s)*4)
+	return algo, sum, err == nil
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeName escapes backslashes, newlines and carriage returns in name like coreutils,
+// ok reports whether there was anything to escape.
+func escapeName(name string) (_ string, ok bool) {
+	if !strings.ContainsAny(name, "\\\n\r") {
+		return name, false
+	}
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`).Replace(name), true
+}
+
+// displayName returns name escaped for messages that aren't checksum lines, so it can't span lines.
+func displayName(name string) string {
+	s, _ := escapeName(name)
+	return s
+}
+
+// unescapeName reverses escapeName, ok is false for any other escape sequence.
+func unescapeName(s string) (_ string, ok bool) {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		if i++; i == len(s) {
+			return "", false
+		}
+		switch s[i] {
+		case '\\':
+			b = append(b, '\\')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		default:
+			return "", false
+		}
+	}
+	return string(b), true
+}
+
+// newRecordScanner returns a scanner over the lines of r, or its NUL terminated records with -z.
+func newRecordScanner(r io.Reader) *bufio.Scanner {
+	sc := bufio.NewScanner(r)
+	if *zeroArg {
+		sc.Split(scanNUL)
+	}
+	return sc
+}
+
+// scanNUL is a bufio.SplitFunc for NUL terminated records, the last one may be unterminated.
+func scanNUL(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
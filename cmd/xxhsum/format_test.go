@@ -0,0 +1,238 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+func TestFormat(t *testing.T) {
+	for _, c := range []struct {
+		e    entry
+		tag  bool
+		want string
+	}{
+		{entry{name: "a.txt", sum: 0xef46db3751d8e999, algo: xxhash.XXH64}, false, "ef46db3751d8e999  a.txt"},
+		{entry{name: "a.txt", sum: 0x02cc5d05, algo: xxhash.XXH32}, false, "02cc5d05  a.txt"},
+		{entry{name: "a b.txt", sum: 0xef46db3751d8e999, algo: xxhash.XXH64}, true, "XXH64 (a b.txt) = ef46db3751d8e999"},
+		{entry{name: "a.txt", sum: 0x02cc5d05, algo: xxhash.XXH32}, true, "XXH32 (a.txt) = 02cc5d05"},
+	} {
+		if got := c.e.format(c.tag); got != c.want {
+			t.Errorf("got %q; want %q", got, c.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, c := range []struct {
+		ln   string
+		want entry
+	}{
+		{"ef46db3751d8e999  a.txt", entry{name: "a.txt", sum: 0xef46db3751d8e999, algo: xxhash.XXH64, seed: 7}},
+		{"EF46DB3751D8E999 *a.txt", entry{name: "a.txt", sum: 0xef46db3751d8e999, algo: xxhash.XXH64, seed: 7}},
+		{"02cc5d05  dir/a  b.txt", entry{name: "dir/a  b.txt", sum: 0x02cc5d05, algo: xxhash.XXH32, seed: 7}},
+		{"02cc5d05  a.txt\r", entry{name: "a.txt", sum: 0x02cc5d05, algo: xxhash.XXH32, seed: 7}},
+		{"XXH64 (a (1).txt) = ef46db3751d8e999", entry{name: "a (1).txt", sum: 0xef46db3751d8e999, algo: xxhash.XXH64, seed: 7, tag: true}},
+		{"XXH32 (a.txt) = 02cc5d05", entry{name: "a.txt", sum: 0x02cc5d05, algo: xxhash.XXH32, seed: 7, tag: true}},
+	} {
+		e, ok, err := newParser(7).parse(c.ln)
+		if err != nil || !ok || e != c.want {
+			t.Errorf("%q: got %+v, %v, %v; want %+v", c.ln, e, ok, err, c.want)
+		}
+	}
+
+	for _, ln := range []string{
+		"ef46db3751d8e99  a.txt",
+		"ef46db3751d8e999 a.txt",
+		"ef46db3751d8e999  ",
+		"zf46db3751d8e999  a.txt",
+		"XXH32 (a.txt) = ef46db3751d8e999",
+		"MD5 (a.txt) = d41d8cd98f00b204e9800998ecf8427e",
+		"17241709254077376921\ta.txt",
+	} {
+		if _, _, err := newParser(0).parse(ln); err != errMalformed {
+			t.Errorf("%q: expected errMalformed, got %v", ln, err)
+		}
+	}
+}
+
+func TestParseLegacy(t *testing.T) {
+	lines := []string{
+		"# seed 42",
+		"# 32bit",
+		"46947589  \ta.txt",
+		"# 64bit",
+		"17241709254077376921\tb c.txt",
+		"17241709254077376921\t e.txt\t",
+		"# a comment",
+		"",
+		"ef46db3751d8e999  d.txt",
+	}
+	want := []entry{
+		{name: "a.txt", sum: 46947589, algo: xxhash.XXH32, seed: 42, legacy: true},
+		{name: "b c.txt", sum: 17241709254077376921, algo: xxhash.XXH64, seed: 42, legacy: true},
+		{name: " e.txt\t", sum: 17241709254077376921, algo: xxhash.XXH64, seed: 42, legacy: true},
+		{name: "d.txt", sum: 0xef46db3751d8e999, algo: xxhash.XXH64},
+	}
+
+	p := newParser(0)
+	var got []entry
+	for _, ln := range lines {
+		e, ok, err := p.parse(ln)
+		if err != nil {
+			t.Fatalf("%q: %v", ln, err)
+		}
+		if ok {
+			got = append(got, e)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v; want %+v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("got %+v; want %+v", got[i], want[i])
+		}
+	}
+}
+
+// adversarialNames are valid file names on unix that break naive checksum line parsing.
+var adversarialNames = []string{
+	"a\nb",
+	"a\\nb",
+	`a\`,
+	`\`,
+	"a\tb",
+	"a\r",
+	"\r\n",
+	" a ",
+	"a  b",
+	"* a",
+	"#a",
+	"a (1)",
+	"a) = 0123456789abcdef",
+	"ef46db3751d8e999  a",
+}
+
+func TestEscapedNames(t *testing.T) {
+	e := entry{name: "a\n\\b", sum: 0xef46db3751d8e999, algo: xxhash.XXH64}
+	if got, want := e.format(false), `\ef46db3751d8e999  a\n\\b`; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	if got, want := e.format(true), `\XXH64 (a\n\\b) = ef46db3751d8e999`; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	for _, name := range adversarialNames {
+		for _, tag := range []bool{false, true} {
+			e := entry{name: name, sum: 0xef46db3751d8e999, algo: xxhash.XXH64, tag: tag}
+			ln := e.format(tag)
+			if strings.ContainsAny(ln, "\n\r") {
+				t.Errorf("%q: line %q spans lines", name, ln)
+			}
+			if got, ok, err := newParser(0).parse(ln); err != nil || !ok || got != e {
+				t.Errorf("%q: got %+v, %v, %v; want %+v", ln, got, ok, err, e)
+			}
+
+			p := newParser(0)
+			p.raw = true
+			ln = e.formatRaw(tag)
+			if got, ok, err := p.parse(ln); err != nil || !ok || got != e {
+				t.Errorf("raw %q: got %+v, %v, %v; want %+v", ln, got, ok, err, e)
+			}
+		}
+	}
+
+	for _, ln := range []string{
+		`\ef46db3751d8e999  a\tb`,
+		`\ef46db3751d8e999  a\`,
+		`\XXH64 (a\x) = ef46db3751d8e999`,
+	} {
+		if _, _, err := newParser(0).parse(ln); err != errMalformed {
+			t.Errorf("%q: expected errMalformed, got %v", ln, err)
+		}
+	}
+}
+
+func TestTreeLines(t *testing.T) {
+	for _, c := range []struct {
+		e    entry
+		want string
+	}{
+		{entry{name: "dir", sum: 0xef46db3751d8e999, algo: xxhash.XXH64, tree: true}, "XXH64-TREE (dir) = ef46db3751d8e999"},
+		{entry{name: "dir", sum: 0xef46db3751d8e999, algo: xxhash.XXH64, tree: true, mode: true}, "XXH64-TREE-MODES (dir) = ef46db3751d8e999"},
+	} {
+		ln := c.e.format(false)
+		if ln != c.want {
+			t.Errorf("got %q; want %q", ln, c.want)
+		}
+		if e, ok, err := newParser(0).parse(ln); err != nil || !ok || e != c.e {
+			t.Errorf("%q: got %+v, %v, %v; want %+v", ln, e, ok, err, c.e)
+		}
+	}
+
+	if _, _, err := newParser(0).parse("XXH64-TREE (dir) = 02cc5d05"); err != errMalformed {
+		t.Errorf("expected errMalformed for a 32 bit tree digest, got %v", err)
+	}
+}
+
+func TestScanNUL(t *testing.T) {
+	defer func(z bool) { *zeroArg = z }(*zeroArg)
+	*zeroArg = true
+
+	sc := newRecordScanner(strings.NewReader("a\nb\x00\x00c d\r\x00e"))
+	var got []string
+	for sc.Scan() {
+		got = append(got, sc.Text())
+	}
+	if want := []string{"a\nb", "", "c d\r", "e"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q; want %q", got, want)
+	}
+}
+
+func TestParseMixed(t *testing.T) {
+	lines := []string{
+		"02cc5d05  a.txt",
+		"ef46db3751d8e999  b.txt",
+		"XXH32 (c.txt) = 02cc5d05",
+		"XXH64 (d.txt) = ef46db3751d8e999",
+	}
+	want := []xxhash.Algorithm{xxhash.XXH32, xxhash.XXH64, xxhash.XXH32, xxhash.XXH64}
+
+	p := newParser(0)
+	for i, ln := range lines {
+		e, ok, err := p.parse(ln)
+		if err != nil || !ok || e.algo != want[i] {
+			t.Errorf("%q: got %v, %v, %v; want %v", ln, e.algo, ok, err, want[i])
+		}
+	}
+
+	for _, ln := range []string{
+		"XXH3 (a.txt) = 2d06800538d394c2",
+		"XXH128 (a.txt) = 99aa06d3014798d86001c324468d497f",
+		"XXH3_2d06800538d394c2  a.txt",
+		"99aa06d3014798d86001c324468d497f  a.txt",
+	} {
+		if _, _, err := p.parse(ln); err != errUnsupported {
+			t.Errorf("%q: expected errUnsupported, got %v", ln, err)
+		}
+	}
+}
+
+func TestParseAlgo(t *testing.T) {
+	for s, want := range map[string]xxhash.Algorithm{"xxh32": xxhash.XXH32, "XXH64": xxhash.XXH64} {
+		if a, err := parseAlgo(s); err != nil || a != want {
+			t.Errorf("%q: got %v, %v; want %v", s, a, err, want)
+		}
+	}
+	for _, s := range []string{"xxh3", "xxh128"} {
+		if _, err := parseAlgo(s); err != errUnsupported {
+			t.Errorf("%q: expected errUnsupported, got %v", s, err)
+		}
+	}
+	if _, err := parseAlgo("md5"); err == nil {
+		t.Error("expected an error for md5")
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/OneOfOne/xxhash/cmd/xxhsum
 
-go 1.11
+go 1.18
 
 require github.com/OneOfOne/xxhash v0.0.0-00010101000000-000000000000
 
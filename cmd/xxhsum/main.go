@@ -1,14 +1,13 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"runtime"
-	"strconv"
-	"strings"
 	"sync"
 
 	"github.com/OneOfOne/xxhash"
@@ -17,150 +16,338 @@ import (
 var (
 	mux      sync.Mutex
 	errored  bool
-	use32    = flag.Bool("32", false, "use 32bit hash instead of 64bit")
+	use32    = flag.Bool("32", false, "alias for -H0")
+	h0Arg    = flag.Bool("H0", false, "use XXH32")
+	h1Arg    = flag.Bool("H1", false, "use XXH64 (default)")
+	h2Arg    = flag.Bool("H2", false, "use XXH128 (not supported)")
+	h3Arg    = flag.Bool("H3", false, "use XXH3 (not supported)")
+	algoArg  = flag.String("algo", "", "use `algorithm`: xxh32, xxh64, xxh3 or xxh128")
 	checkArg = flag.Bool("c", false, "read and check the sums of input files")
 	seedArg  = flag.Uint64("s", 0, "use `seed` to seed the hasher")
+	tagArg   = flag.Bool("tag", false, "create BSD-style checksums")
+
+	quietArg         = flag.Bool("quiet", false, "don't print OK for each successfully verified file")
+	statusArg        = flag.Bool("status", false, "don't output anything, the exit code shows success")
+	strictArg        = flag.Bool("strict", false, "exit non-zero for improperly formatted checksum lines")
+	warnArg          = flag.Bool("warn", false, "warn about improperly formatted checksum lines")
+	ignoreMissingArg = flag.Bool("ignore-missing", false, "don't fail or report status for missing files")
+
+	recursiveArg      = flag.Bool("r", false, "hash the files in directories recursively")
+	followSymlinksArg = flag.Bool("follow-symlinks", false, "follow symbolic links with -r and --tree")
+	oneFileSystemArg  = flag.Bool("one-file-system", false, "don't descend into directories on other file systems with -r and --tree")
+	hiddenArg         = flag.Bool("hidden", false, "include files and directories starting with a dot with -r, --tree always includes them")
+	treeArg           = flag.Bool("tree", false, "print a single XXH64 digest for each directory tree")
+	treeModesArg      = flag.Bool("tree-modes", false, "include permission bits in --tree digests")
+	cacheArg          = flag.String("cache", "", "cache `file` for update, defaults to the manifest name with .cache appended")
+	formatArg         = flag.String("format", "text", "output `format`: text, json, jsonl or csv")
+	unorderedArg      = flag.Bool("unordered", false, "print results as soon as they're ready instead of in input order")
+	zeroArg           = flag.Bool("z", false, "end each output line with NUL instead of newline and don't escape file names, also read -c and --files-from input that way")
+	filesFromArg      = flag.String("files-from", "", "also hash the files listed in `file`, one per line or NUL terminated with -z, - reads stdin")
+	includeArg        stringList
+	excludeArg        stringList
 )
 
 func init() {
-	flag.Parse()
+	flag.Var(&includeArg, "include", "only hash files matching `glob` with -r and --tree, can be repeated")
+	flag.Var(&excludeArg, "exclude", "skip files and directories matching `glob` with -r and --tree, can be repeated")
+	flag.BoolVar(zeroArg, "zero", false, "alias for -z")
+}
+
+func init() {
 	flag.Usage = func() {
-		errorf("Usage of %s: [-32] [-c] [-s seed] files...\t%s *.go > sums.xx\t%s -c sums.xx", os.Args[0], os.Args[0], os.Args[0])
+		errorf("Usage of %s: [-H0|-H1] [--algo=name] [-c] [-s seed] [--tag] [-r|--tree] [-z] [--files-from file] files...\t%s *.go > sums.xx\t%s -c sums.xx", os.Args[0], os.Args[0], os.Args[0])
+		errorf("       %s diff old.xx new.xx\t%s update [--cache file] sums.xx", os.Args[0], os.Args[0])
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 }
 
 func main() {
+	flag.Parse()
+	if cmd := flag.Arg(0); cmd == "diff" || cmd == "update" {
+		// allow flags after the subcommand too, use ./diff or ./update for files with those names.
+		flag.CommandLine.Parse(flag.Args()[1:])
+		os.Exit(runCommand(cmd, flag.Args()))
+	}
+
 	args := flag.Args()
-	st, _ := os.Stdin.Stat()
-	if st.Mode()&os.ModeCharDevice == 0 {
+	if *filesFromArg != "" {
+		names, err := readNames(*filesFromArg)
+		if err != nil {
+			errorf("%v", displayError(err))
+			os.Exit(1)
+		}
+		args = append(args, names...)
+	}
+	if len(args) == 0 && *filesFromArg == "" {
+		// like upstream, stdin is only read when no files were given.
+		if st, err := os.Stdin.Stat(); err != nil || st.Mode()&os.ModeCharDevice != 0 {
+			flag.Usage()
+		}
 		args = append(args, "-")
 	}
-	if len(args) == 0 {
-		flag.Usage()
+	algo, err := selectedAlgo()
+	if err != nil {
+		errorf("%v", err)
+		os.Exit(1)
+	}
+	if *treeArg && algo != xxhash.XXH64 {
+		errorf("--tree only supports XXH64")
+		os.Exit(1)
 	}
-	sema := newSema(runtime.NumCPU())
-	var wg sync.WaitGroup
-	wg.Add(len(args))
-	if !*checkArg {
-		printf("# seed %d", *seedArg)
-		if *use32 {
-			printf("# 32bit")
-		} else {
-			printf("# 64bit")
+	if out, err = newRecordWriter(*formatArg, os.Stdout); err != nil {
+		errorf("%v", err)
+		os.Exit(1)
+	}
+	for _, p := range append(includeArg, excludeArg...) {
+		if _, err := filepath.Match(p, ""); err != nil {
+			errorf("invalid pattern %q: %v", p, err)
+			os.Exit(1)
 		}
 	}
+	o := newOrderer(newSema(runtime.NumCPU()), reorderWindow(), *unorderedArg)
 	for _, fn := range args {
 		if *checkArg {
 			check(newSema(runtime.NumCPU()), fn)
+		} else if *treeArg {
+			o.Run(treeJob(fn))
+		} else if st, err := os.Stat(fn); *recursiveArg && err == nil && st.IsDir() {
+			for _, fn := range walkTree(fn) {
+				o.Run(hashJob(fn, algo))
+			}
 		} else {
-			sema.Run(func() { printHash(fn) })
+			o.Run(hashJob(fn, algo))
 		}
 	}
-	sema.WaitAndClose()
+	o.Wait()
+	out.close()
 	if errored {
 		os.Exit(1)
 	}
 }
 
-func check(sema *sema, fn string) {
-	defer sema.WaitAndClose()
-	var err error
-	var f *os.File
-	if fn == "-" {
-		f = os.Stdin
-	} else {
-		if f, err = os.Open(fn); err != nil {
-			errorf("error opening %s: %v", fn, err)
-			return
+// runCommand runs the diff or update subcommand and returns the exit code.
+func runCommand(cmd string, args []string) int {
+	switch cmd {
+	case "diff":
+		// like diff(1): 0 if the manifests are the same, 1 if they differ and 2 on errors.
+		if len(args) != 2 {
+			flag.Usage()
 		}
-		defer f.Close()
-	}
-	buf := bufio.NewScanner(f)
-	for buf.Scan() {
-		ln := strings.TrimSpace(buf.Text())
-		if len(ln) == 0 {
-			continue
+		var manifests [2][]entry
+		for i, fn := range args {
+			// a malformed line would show up as a removed or added file.
+			entries, malformed, err := readManifest(fn)
+			if err == nil && malformed > 0 {
+				err = fmt.Errorf("%s: %d improperly formatted %s", displayName(fn), malformed, plural(malformed, "line", "lines"))
+			}
+			if err != nil {
+				errorf("%v", displayError(err))
+				return 2
+			}
+			manifests[i] = entries
 		}
-		if strings.HasPrefix(ln, "# seed ") {
-			*seedArg, _ = strconv.ParseUint(strings.TrimSpace(ln[7:]), 10, 64)
-			continue
+		d := diffManifests(manifests[0], manifests[1])
+		d.write(os.Stdout)
+		if d.empty() {
+			return 0
 		}
-		if strings.HasPrefix(ln, "# 32bit") {
-			*use32 = true
-			continue
+		return 1
+
+	case "update":
+		if len(args) != 1 || args[0] == "-" {
+			flag.Usage()
 		}
-		if strings.HasPrefix(ln, "# 64bit") {
-			*use32 = false
-			continue
+		cacheFn := *cacheArg
+		if cacheFn == "" {
+			cacheFn = args[0] + ".cache"
 		}
-		if ln[0] == '#' {
-			continue
+		st, err := update(newSema(runtime.NumCPU()), args[0], cacheFn)
+		if err != nil {
+			errorf("%v", displayError(err))
+			return 1
 		}
-		parts := strings.SplitN(ln, "\t", 2)
-		if len(parts) != 2 || len(parts[0]) < 1 {
-			continue
+		if !*statusArg {
+			warnf("xxhsum: %d updated, %d removed, %d unchanged, %d rehashed", st.updated, st.removed, st.unchanged, st.rehashed)
 		}
-		oh, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 64)
+	}
+	return 0
+}
+
+// selectedAlgo returns the algorithm chosen by -32, -H0..-H3 and --algo, XXH64 if none were set.
+func selectedAlgo() (xxhash.Algorithm, error) {
+	var algos []xxhash.Algorithm
+	if *use32 || *h0Arg {
+		algos = append(algos, xxhash.XXH32)
+	}
+	if *h1Arg {
+		algos = append(algos, xxhash.XXH64)
+	}
+	if *h2Arg || *h3Arg {
+		return 0, errUnsupported
+	}
+	if *algoArg != "" {
+		a, err := parseAlgo(*algoArg)
 		if err != nil {
-			errorf("error: %v", err)
-			continue
+			return 0, err
 		}
-		sema.Run(func() {
-			fn := strings.TrimSpace(parts[1])
-			nh, err := hashFile(fn)
+		algos = append(algos, a)
+	}
+
+	if len(algos) == 0 {
+		return xxhash.XXH64, nil
+	}
+	for _, a := range algos[1:] {
+		if a != algos[0] {
+			return 0, errors.New("conflicting algorithm flags")
+		}
+	}
+	return algos[0], nil
+}
+
+// reorderWindow is how many results may wait on a slower file before hashing pauses.
+func reorderWindow() int { return 4 * runtime.NumCPU() }
+
+// hashJob returns an orderer job that hashes fn and prints its line.
+func hashJob(fn string, algo xxhash.Algorithm) func() func() {
+	return func() func() {
+		h, n, err := hashFileSize(fn, algo, *seedArg)
+		return func() {
 			if err != nil {
-				errorf("error hashing %s: %v", fn, err)
+				setErrored()
 			}
-			if oh != nh {
-				errorf("hash mismatch %q 0x%X 0x%X", fn, oh, nh)
+			out.write(newRecord(entry{name: fn, sum: h, algo: algo, seed: *seedArg}, n, err))
+		}
+	}
+}
+
+// treeJob returns an orderer job that prints the --tree digest of root.
+func treeJob(root string) func() func() {
+	return func() func() {
+		e := entry{name: root, algo: xxhash.XXH64, seed: *seedArg, tree: true, mode: *treeModesArg}
+		h, n, err := hashEntry(e)
+		return func() {
+			if err != nil {
+				setErrored()
 			}
-		})
+			e.sum = h
+			out.write(newRecord(e, n, err))
+		}
 	}
 }
 
-func printHash(fn string) {
-	h, err := hashFile(fn)
+// hashEntry returns the checksum of the file named by e and its size, or the --tree digest of the directory with a size of -1.
+func hashEntry(e entry) (h uint64, n int64, err error) {
+	if !e.tree {
+		return hashFileSize(e.name, e.algo, e.seed)
+	}
+	st, err := os.Stat(e.name)
+	if err == nil && !st.IsDir() {
+		err = errors.New("not a directory")
+	}
 	if err != nil {
-		errorf("error hashing %s: %v", fn, err)
-		return
+		return 0, -1, err
 	}
-	if h == 0 {
-		return
+	h, err = treeDigest(newSema(runtime.NumCPU()), newWalker(), e.name, e.seed, e.mode)
+	return h, -1, err
+}
+
+// readNames returns the file names listed in fn for --files-from, one per line or NUL terminated with -z.
+// Names are used as is, so names with newlines need -z. Empty names are skipped.
+func readNames(fn string) (names []string, err error) {
+	f := os.Stdin
+	if fn != "-" {
+		if f, err = os.Open(fn); err != nil {
+			return nil, err
+		}
+		defer f.Close()
 	}
-	if *use32 {
-		printf("%-10d\t%s", h, fn)
-	} else {
-		printf("%-20d\t%s", h, fn)
+	sc := newRecordScanner(f)
+	for sc.Scan() {
+		if name := sc.Text(); name != "" {
+			names = append(names, name)
+		}
 	}
+	return names, sc.Err()
 }
 
-func hashFile(fn string) (h uint64, err error) {
+// walkTree returns the files under root for -r, sorted by path.
+func walkTree(root string) []string {
+	return newWalker().walk(root)
+}
+
+// newWalker returns a walker configured by the command line flags.
+func newWalker() *walker {
+	return &walker{
+		include:        includeArg,
+		exclude:        excludeArg,
+		followSymlinks: *followSymlinksArg,
+		oneFileSystem:  *oneFileSystemArg,
+		hidden:         *hiddenArg,
+		onError:        func(err error) { errorf("%v", displayError(err)) },
+	}
+}
+
+// fileTypeError is returned by hashFile for directories and special files, which aren't hashed.
+type fileTypeError struct {
+	mode os.FileMode
+}
+
+func (e *fileTypeError) Error() string {
+	m := e.mode
+	switch {
+	case m.IsDir():
+		if !*recursiveArg && !*checkArg {
+			return "is a directory, use -r to hash the files in it"
+		}
+		return "is a directory"
+	case m&os.ModeCharDevice != 0:
+		return "is a character device, not a regular file"
+	case m&os.ModeDevice != 0:
+		return "is a block device, not a regular file"
+	case m&os.ModeSocket != 0:
+		return "is a socket, not a regular file"
+	}
+	return "is not a regular file"
+}
+
+// hashFile returns the checksum of fn, empty files hash to the checksum of the empty input.
+// Named pipes are hashed so process substitution works, directories and other special files return a *fileTypeError.
+func hashFile(fn string, algo xxhash.Algorithm, seed uint64) (h uint64, err error) {
+	h, _, err = hashFileSize(fn, algo, seed)
+	return
+}
+
+// hashFileSize is like hashFile but also returns the number of bytes hashed.
+func hashFileSize(fn string, algo xxhash.Algorithm, seed uint64) (h uint64, n int64, err error) {
 	var f *os.File
 	if fn == "-" {
 		f = os.Stdin
 	} else {
+		st, err := os.Stat(fn)
+		if err != nil {
+			return 0, 0, err
+		}
+		if m := st.Mode(); !m.IsRegular() && m&os.ModeNamedPipe == 0 {
+			return 0, 0, &fileTypeError{m}
+		}
 		if f, err = os.Open(fn); err != nil {
-			return
+			return 0, 0, err
 		}
 		defer f.Close()
-		if st, _ := f.Stat(); st.IsDir() || st.Size() == 0 {
-			return
-		}
 	}
-	if *use32 {
-		xx := xxhash.NewS32(uint32(*seedArg))
-		if _, err = io.Copy(xx, f); err != nil {
+	if algo == xxhash.XXH32 {
+		xx := xxhash.NewS32(uint32(seed))
+		if n, err = io.Copy(xx, f); err != nil {
 			return
 		}
-		return uint64(xx.Sum32()), nil
+		return uint64(xx.Sum32()), n, nil
 	}
-	xx := xxhash.NewS64(*seedArg)
-	if _, err = io.Copy(xx, f); err != nil {
+	xx := xxhash.NewS64(seed)
+	if n, err = io.Copy(xx, f); err != nil {
 		return
 	}
-	return xx.Sum64(), nil
+	return xx.Sum64(), n, nil
 }
 
 func printf(f string, args ...interface{}) {
@@ -175,3 +362,16 @@ func errorf(f string, args ...interface{}) {
 	errored = true
 	mux.Unlock()
 }
+
+// warnf is like errorf but doesn't change the exit code.
+func warnf(f string, args ...interface{}) {
+	mux.Lock()
+	fmt.Fprintf(os.Stderr, f+"\n", args...)
+	mux.Unlock()
+}
+
+func setErrored() {
+	mux.Lock()
+	errored = true
+	mux.Unlock()
+}
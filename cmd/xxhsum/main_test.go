@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+func TestHashFileEmpty(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(fn, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		algo xxhash.Algorithm
+		seed uint64
+		want uint64
+	}{
+		{xxhash.XXH64, 0, 0xef46db3751d8e999},
+		{xxhash.XXH64, 42, xxhash.Checksum64S(nil, 42)},
+		{xxhash.XXH32, 0, 0x02cc5d05},
+		{xxhash.XXH32, 42, uint64(xxhash.Checksum32S(nil, 42))},
+	} {
+		h, err := hashFile(fn, c.algo, c.seed)
+		if err != nil || h != c.want {
+			t.Errorf("%v/%d: got %#x, %v; want %#x", c.algo, c.seed, h, err, c.want)
+		}
+	}
+}
+
+func TestHashFileDir(t *testing.T) {
+	_, err := hashFile(t.TempDir(), xxhash.XXH64, 0)
+	if fe, ok := err.(*fileTypeError); !ok || !fe.mode.IsDir() {
+		t.Fatalf("expected a directory fileTypeError, got %v", err)
+	}
+}
+
+func TestHashFileDevice(t *testing.T) {
+	st, err := os.Stat(os.DevNull)
+	if err != nil || st.Mode()&os.ModeCharDevice == 0 {
+		t.Skip(os.DevNull, "isn't a character device")
+	}
+	if _, err := hashFile(os.DevNull, xxhash.XXH64, 0); err == nil {
+		t.Fatal("expected an error for a character device")
+	} else if _, ok := err.(*fileTypeError); !ok {
+		t.Fatalf("expected a fileTypeError, got %v", err)
+	}
+}
+
+func TestReadNames(t *testing.T) {
+	defer func(z bool) { *zeroArg = z }(*zeroArg)
+	fn := filepath.Join(t.TempDir(), "names")
+
+	for _, c := range []struct {
+		zero bool
+		data string
+		want []string
+	}{
+		{false, "a\n b \n\nc\td\n", []string{"a", " b ", "c\td"}},
+		{true, "a\nb\x00\x00 c\\\x00", []string{"a\nb", " c\\"}},
+	} {
+		if err := os.WriteFile(fn, []byte(c.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		*zeroArg = c.zero
+		got, err := readNames(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %q; want %q", c.data, got, c.want)
+		}
+	}
+}
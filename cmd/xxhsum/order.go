@@ -0,0 +1,66 @@
+package main
+
+import "sync"
+
+// orderer runs jobs on a sema and calls the function each job returns in the order the jobs were submitted,
+// holding at most window results that are waiting on an earlier job.
+// With unordered set, results are emitted as soon as their job is done.
+type orderer struct {
+	sema      *sema
+	unordered bool
+	slots     chan struct{} // a slot is held from Run until the result is emitted
+
+	mu      sync.Mutex
+	seq     int
+	next    int
+	pending map[int]func()
+}
+
+func newOrderer(sema *sema, window int, unordered bool) *orderer {
+	return &orderer{
+		sema:      sema,
+		unordered: unordered,
+		slots:     make(chan struct{}, window),
+		pending:   make(map[int]func(), window),
+	}
+}
+
+// Run schedules job, it blocks while the reorder buffer is full and must not be called concurrently.
+func (o *orderer) Run(job func() (emit func())) {
+	if o.unordered {
+		o.sema.Run(func() {
+			emit := job()
+			o.mu.Lock()
+			emit()
+			o.mu.Unlock()
+		})
+		return
+	}
+
+	o.slots <- struct{}{}
+	seq := o.seq
+	o.seq++
+
+	o.sema.Run(func() {
+		emit := job()
+
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		o.pending[seq] = emit
+		for {
+			emit, ok := o.pending[o.next]
+			if !ok {
+				break
+			}
+			delete(o.pending, o.next)
+			emit()
+			o.next++
+			<-o.slots
+		}
+	})
+}
+
+// Wait waits for every result to be emitted and stops the sema.
+func (o *orderer) Wait() {
+	o.sema.WaitAndClose()
+}
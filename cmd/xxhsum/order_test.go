@@ -0,0 +1,61 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestOrderer(t *testing.T) {
+	const (
+		n      = 200
+		window = 8
+	)
+
+	var (
+		o          = newOrderer(newSema(4), window, false)
+		got        []int
+		maxPending int
+	)
+	for i := 0; i < n; i++ {
+		i := i
+		o.Run(func() func() {
+			time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+			return func() { got = append(got, i) }
+		})
+		// o.mu is held while pending results are emitted, so this sees a consistent count.
+		o.mu.Lock()
+		if len(o.pending) > maxPending {
+			maxPending = len(o.pending)
+		}
+		o.mu.Unlock()
+	}
+	o.Wait()
+
+	if len(got) != n {
+		t.Fatalf("got %d results; want %d", len(got), n)
+	}
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("result %d emitted at position %d", v, i)
+		}
+	}
+	if maxPending > window {
+		t.Fatalf("%d results were buffered, more than the window of %d", maxPending, window)
+	}
+}
+
+func TestOrdererUnordered(t *testing.T) {
+	var (
+		o    = newOrderer(newSema(4), 1, true)
+		seen = map[int]bool{}
+	)
+	for i := 0; i < 100; i++ {
+		i := i
+		o.Run(func() func() { return func() { seen[i] = true } })
+	}
+	o.Wait()
+	if len(seen) != 100 {
+		t.Fatalf("got %d results; want 100", len(seen))
+	}
+}
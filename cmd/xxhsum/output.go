@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"unicode/utf8"
+)
+
+// check mode statuses.
+const (
+	statusOK         = "ok"
+	statusFailed     = "failed"
+	statusUnreadable = "unreadable"
+	statusMissing    = "missing"
+)
+
+// record is one result in the json, jsonl and csv output formats.
+type record struct {
+	Path       string `json:"path"`
+	PathBase64 string `json:"path_base64,omitempty"` // the exact path, only set if it isn't valid UTF-8, which JSON can't hold
+	Algorithm  string `json:"algorithm"`
+	Seed       uint64 `json:"seed"`
+	Digest     string `json:"digest,omitempty"`  // hex
+	Decimal    string `json:"decimal,omitempty"` // a string so it survives JSON parsers that use float64
+	Size       *int64 `json:"size,omitempty"`    // bytes hashed, not set for --tree
+
+	Status   string `json:"status,omitempty"`   // check mode only
+	Expected string `json:"expected,omitempty"` // check mode only, hex
+	Error    string `json:"error,omitempty"`
+
+	e   entry
+	err error
+}
+
+var csvHeader = []string{"path", "algorithm", "seed", "digest", "decimal", "size", "status", "expected", "error"}
+
+// newRecord returns the record for e, size is ignored if it's negative or err is set.
+func newRecord(e entry, size int64, err error) *record {
+	r := &record{
+		Path:      e.name,
+		Algorithm: e.algoName(),
+		Seed:      e.seed,
+		e:         e,
+		err:       err,
+	}
+	if !utf8.ValidString(e.name) {
+		r.PathBase64 = base64.StdEncoding.EncodeToString([]byte(e.name))
+	}
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.Digest, r.Decimal = e.hex(), strconv.FormatUint(e.sum, 10)
+	if size >= 0 {
+		r.Size = &size
+	}
+	return r
+}
+
+func (r *record) csv() []string {
+	var size string
+	if r.Size != nil {
+		size = strconv.FormatInt(*r.Size, 10)
+	}
+	return []string{r.Path, r.Algorithm, strconv.FormatUint(r.Seed, 10), r.Digest, r.Decimal, size, r.Status, r.Expected, r.Error}
+}
+
+// recordWriter writes results in the format selected with --format, write is called in output order.
+type recordWriter interface {
+	write(r *record)
+	close()
+}
+
+// out is where hash and check results go.
+var out recordWriter = textWriter{}
+
+func newRecordWriter(format string, w io.Writer) (recordWriter, error) {
+	switch format {
+	case "text":
+		return textWriter{}, nil
+	case "json":
+		return &jsonWriter{w: w}, nil
+	case "jsonl":
+		return &jsonWriter{w: w, lines: true}, nil
+	case "csv":
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// textWriter prints checksum lines, or sha256sum -c style results in check mode, errors go to stderr.
+type textWriter struct{}
+
+func (textWriter) write(r *record) {
+	switch r.Status {
+	case "":
+		if r.err != nil {
+			warnf("error hashing %s: %v", displayName(r.Path), displayError(r.err))
+			return
+		}
+		if *zeroArg {
+			printRecord(r.e.formatRaw(*tagArg))
+		} else {
+			printRecord(r.e.format(*tagArg))
+		}
+	case statusOK:
+		printRecord(checkLine(r.Path, "OK"))
+	case statusFailed:
+		printRecord(checkLine(r.Path, "FAILED"))
+	case statusUnreadable:
+		if _, ok := r.err.(*fileTypeError); ok {
+			warnf("%s: %v", displayName(r.Path), r.err)
+		} else {
+			warnf("%v", displayError(r.err))
+		}
+		printRecord(checkLine(r.Path, "FAILED open or read"))
+	}
+}
+
+func (textWriter) close() {}
+
+// displayError is err with the path of an *fs.PathError escaped by displayName.
+func displayError(err error) error {
+	if pe, ok := err.(*fs.PathError); ok {
+		return &fs.PathError{Op: pe.Op, Path: displayName(pe.Path), Err: pe.Err}
+	}
+	return err
+}
+
+// checkLine returns `name: result`, the name is escaped like in checksum lines unless -z is set.
+func checkLine(name, result string) string {
+	if !*zeroArg {
+		if s, ok := escapeName(name); ok {
+			return `\` + s + ": " + result
+		}
+	}
+	return name + ": " + result
+}
+
+// recordEnd is the byte that ends each checksum line or check result, NUL with -z.
+func recordEnd() byte {
+	if *zeroArg {
+		return 0
+	}
+	return '\n'
+}
+
+func printRecord(ln string) {
+	mux.Lock()
+	io.WriteString(os.Stdout, ln+string(recordEnd()))
+	mux.Unlock()
+}
+
+// jsonWriter writes a JSON array of records, or one record per line if lines is set.
+type jsonWriter struct {
+	w     io.Writer
+	lines bool
+	n     int
+}
+
+func (jw *jsonWriter) write(r *record) {
+	b, _ := json.Marshal(r)
+
+	mux.Lock()
+	defer mux.Unlock()
+	switch {
+	case jw.lines:
+	case jw.n == 0:
+		io.WriteString(jw.w, "[\n")
+	default:
+		io.WriteString(jw.w, ",\n")
+	}
+	jw.w.Write(b)
+	if jw.lines {
+		io.WriteString(jw.w, "\n")
+	}
+	jw.n++
+}
+
+func (jw *jsonWriter) close() {
+	if jw.lines {
+		return
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	if jw.n == 0 {
+		io.WriteString(jw.w, "[")
+	}
+	io.WriteString(jw.w, "\n]\n")
+}
+
+// csvWriter writes RFC 4180 CSV with a header row.
+type csvWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (cw *csvWriter) write(r *record) {
+	mux.Lock()
+	defer mux.Unlock()
+	if !cw.wroteHeader {
+		cw.w.Write(csvHeader)
+		cw.wroteHeader = true
+	}
+	cw.w.Write(r.csv())
+	cw.w.Flush()
+}
+
+func (cw *csvWriter) close() {
+	mux.Lock()
+	defer mux.Unlock()
+	if !cw.wroteHeader {
+		cw.w.Write(csvHeader)
+	}
+	cw.w.Flush()
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+func testRecords() []*record {
+	return []*record{
+		newRecord(entry{name: "a\tb\nc \"d\",e", sum: 0xef46db3751d8e999, algo: xxhash.XXH64, seed: 1}, 0, nil),
+		newRecord(entry{name: "tree", sum: 0x02cc5d05, algo: xxhash.XXH32}, -1, nil),
+		newRecord(entry{name: "gone", algo: xxhash.XXH64}, 0, errors.New("no such file")),
+	}
+}
+
+func TestJSONWriter(t *testing.T) {
+	for _, lines := range []bool{false, true} {
+		var buf bytes.Buffer
+		w := &jsonWriter{w: &buf, lines: lines}
+		for _, r := range testRecords() {
+			w.write(r)
+		}
+		w.close()
+
+		var got []map[string]interface{}
+		if lines {
+			for _, ln := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+				var m map[string]interface{}
+				if err := json.Unmarshal([]byte(ln), &m); err != nil {
+					t.Fatalf("%q: %v", ln, err)
+				}
+				got = append(got, m)
+			}
+		} else if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("%s: %v", buf.Bytes(), err)
+		}
+
+		want := []map[string]interface{}{
+			{"path": "a\tb\nc \"d\",e", "algorithm": "XXH64", "seed": 1.0, "digest": "ef46db3751d8e999", "decimal": "17241709254077376921", "size": 0.0},
+			{"path": "tree", "algorithm": "XXH32", "seed": 0.0, "digest": "02cc5d05", "decimal": "46947589"},
+			{"path": "gone", "algorithm": "XXH64", "seed": 0.0, "error": "no such file"},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("lines=%v: got %v; want %v", lines, got, want)
+		}
+	}
+
+	var buf bytes.Buffer
+	w := &jsonWriter{w: &buf}
+	w.close()
+	var got []interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil || len(got) != 0 {
+		t.Fatalf("expected an empty array, got %q", buf.Bytes())
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &csvWriter{w: csv.NewWriter(&buf)}
+	for _, r := range testRecords() {
+		w.write(r)
+	}
+	w.close()
+
+	got, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		csvHeader,
+		{"a\tb\nc \"d\",e", "XXH64", "1", "ef46db3751d8e999", "17241709254077376921", "0", "", "", ""},
+		{"tree", "XXH32", "0", "02cc5d05", "46947589", "", "", "", ""},
+		{"gone", "XXH64", "0", "", "", "", "", "", "no such file"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q; want %q", got, want)
+	}
+}
+
+func TestCheckRecords(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(w recordWriter) { out = w }(out)
+	out = &jsonWriter{w: &buf, lines: true}
+
+	var st checkStats
+	e := entry{name: "f", sum: 1, algo: xxhash.XXH64}
+	st.add(e, 1, 5, nil)
+	st.add(e, 2, 5, nil)
+
+	var ok, failed record
+	lines := strings.Split(buf.String(), "\n")
+	if err := json.Unmarshal([]byte(lines[0]), &ok); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &failed); err != nil {
+		t.Fatal(err)
+	}
+	if ok.Status != statusOK || ok.Digest != ok.Expected || *ok.Size != 5 {
+		t.Fatalf("unexpected record %+v", ok)
+	}
+	if failed.Status != statusFailed || failed.Digest != "0000000000000002" || failed.Expected != "0000000000000001" {
+		t.Fatalf("unexpected record %+v", failed)
+	}
+}
+
+func TestRecordInvalidUTF8(t *testing.T) {
+	const name = "bad\xff"
+	b, err := json.Marshal(newRecord(entry{name: name, algo: xxhash.XXH64}, 0, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r record
+	if err := json.Unmarshal(b, &r); err != nil {
+		t.Fatal(err)
+	}
+	if raw, err := base64.StdEncoding.DecodeString(r.PathBase64); err != nil || string(raw) != name {
+		t.Fatalf("path_base64 %q doesn't round trip to %q: %v", r.PathBase64, name, err)
+	}
+
+	if r := newRecord(entry{name: "ok", algo: xxhash.XXH64}, 0, nil); r.PathBase64 != "" {
+		t.Fatalf("path_base64 set for a valid path: %q", r.PathBase64)
+	}
+}
+
+func TestDisplayError(t *testing.T) {
+	err := displayError(&fs.PathError{Op: "open", Path: "a\nb", Err: fs.ErrNotExist})
+	if got, want := err.Error(), `open a\nb: file does not exist`; got != want {
+		t.Fatalf("got %q; want %q", got, want)
+	}
+}
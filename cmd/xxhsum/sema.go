@@ -12,13 +12,14 @@ func newSema(n int) *sema {
 		ch: make(chan func(), n),
 	}
 	for ; n > 0; n-- {
-		go s.handler()
+		go s.handler(s.ch)
 	}
 	return s
 }
 
-func (s *sema) handler() {
-	for fn := range s.ch {
+// handler gets the channel as an argument, it may only start running after WaitAndClose.
+func (s *sema) handler(ch <-chan func()) {
+	for fn := range ch {
 		fn()
 		s.wg.Done()
 	}
@@ -32,5 +33,4 @@ func (s *sema) Run(fn func()) {
 func (s *sema) WaitAndClose() {
 	s.wg.Wait()
 	close(s.ch)
-	s.ch = nil
 }
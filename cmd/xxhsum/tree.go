@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+// The --tree digest is the XXH64, with the -s seed, of this encoding of the tree.
+// Hidden files and directories are always included, --include and --exclude still apply.
+//
+//	"xxhsum-tree" 0x00 version(1) flags(1)
+//	for every entry under the root, sorted by the byte-wise order of its path:
+//		type(1)          'f' file, 'd' directory, 'l' symlink, 'p' named pipe, 's' socket,
+//		                 'c' character device, 'b' block device, '?' anything else
+//		len(8) path      path relative to the root, '/' separated, the root itself isn't listed
+//		mode(4)          only with --tree-modes: permission, setuid (04000), setgid (02000) and sticky (01000) bits
+//		size(8) sum(8)   files only: the size and the XXH64 of the contents with the -s seed
+//		len(8) target    symlinks only: the link target as stored, symlinks are never followed
+//		                 unless --follow-symlinks is set, in which case they appear as what they point to
+//
+// Integers are little endian, len is the byte length of the string that follows.
+// flags has bit 1 set if modes are included and bit 2 if --include or --exclude filtered the entries.
+const (
+	treeMagic    = "xxhsum-tree\x00"
+	treeVersion  = 1
+	treeModes    = 1
+	treeFiltered = 2
+)
+
+// treeEntry is a walkEntry with what the encoding needs to know about it.
+type treeEntry struct {
+	walkEntry
+	perm   uint32
+	size   uint64
+	sum    uint64
+	target string
+}
+
+// treeDigest returns the digest of the tree under root, the files are hashed on sema.
+func treeDigest(sema *sema, w *walker, root string, seed uint64, modes bool) (uint64, error) {
+	var flags byte
+	if modes {
+		flags |= treeModes
+	}
+	if len(w.include) > 0 || len(w.exclude) > 0 {
+		flags |= treeFiltered
+	}
+	w.hidden = true
+
+	// a directory that can't be read would silently change the digest, so it fails the whole tree.
+	var walkErr error
+	w.onError = func(err error) {
+		if walkErr == nil {
+			walkErr = err
+		}
+	}
+
+	wes := w.entries(root)
+	if walkErr != nil {
+		sema.WaitAndClose()
+		return 0, walkErr
+	}
+
+	var (
+		entries = make([]treeEntry, len(wes))
+		errs    = make([]error, len(wes))
+	)
+
+	for i, we := range wes {
+		i, e := i, &entries[i]
+		e.walkEntry = we
+		sema.Run(func() { errs[i] = e.load(seed, modes) })
+	}
+	sema.WaitAndClose()
+
+	for _, err := range errs {
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	xx := xxhash.NewS64(seed)
+	writeTree(xx, entries, flags)
+	return xx.Sum64(), nil
+}
+
+// load fills in the mode, contents checksum or link target of e.
+func (e *treeEntry) load(seed uint64, modes bool) error {
+	if modes {
+		fi, err := os.Lstat(e.path)
+		if err == nil && fi.Mode()&os.ModeSymlink != 0 && e.mode&os.ModeSymlink == 0 {
+			fi, err = os.Stat(e.path) // a followed symlink
+		}
+		if err != nil {
+			return err
+		}
+		e.perm = unixPerm(fi.Mode())
+	}
+
+	switch {
+	case e.mode.IsRegular():
+		f, err := os.Open(e.path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		xx := xxhash.NewS64(seed)
+		n, err := io.Copy(xx, f)
+		if err != nil {
+			return err
+		}
+		e.size, e.sum = uint64(n), xx.Sum64()
+	case e.mode&os.ModeSymlink != 0:
+		target, err := os.Readlink(e.path)
+		if err != nil {
+			return err
+		}
+		e.target = target
+	}
+	return nil
+}
+
+func writeTree(w io.Writer, entries []treeEntry, flags byte) {
+	modes := flags&treeModes != 0
+
+	b := append([]byte(treeMagic), treeVersion, flags)
+	for _, e := range entries {
+		b = append(b, treeType(e.mode))
+		b = appendString(b, e.rel)
+		if modes {
+			b = appendUint32(b, e.perm)
+		}
+		switch {
+		case e.mode.IsRegular():
+			b = appendUint64(b, e.size)
+			b = appendUint64(b, e.sum)
+		case e.mode&os.ModeSymlink != 0:
+			b = appendString(b, e.target)
+		}
+
+		if len(b) >= 32<<10 {
+			w.Write(b)
+			b = b[:0]
+		}
+	}
+	w.Write(b)
+}
+
+func appendUint32(b []byte, x uint32) []byte {
+	var a [4]byte
+	binary.LittleEndian.PutUint32(a[:], x)
+	return append(b, a[:]...)
+}
+
+func appendUint64(b []byte, x uint64) []byte {
+	var a [8]byte
+	binary.LittleEndian.PutUint64(a[:], x)
+	return append(b, a[:]...)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = appendUint64(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func treeType(m os.FileMode) byte {
+	switch {
+	case m.IsRegular():
+		return 'f'
+	case m.IsDir():
+		return 'd'
+	case m&os.ModeSymlink != 0:
+		return 'l'
+	case m&os.ModeNamedPipe != 0:
+		return 'p'
+	case m&os.ModeSocket != 0:
+		return 's'
+	case m&os.ModeCharDevice != 0:
+		return 'c'
+	case m&os.ModeDevice != 0:
+		return 'b'
+	}
+	return '?'
+}
+
+// unixPerm returns the permission bits of m in their unix positions.
+func unixPerm(m os.FileMode) uint32 {
+	p := uint32(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		p |= 04000
+	}
+	if m&os.ModeSetgid != 0 {
+		p |= 02000
+	}
+	if m&os.ModeSticky != 0 {
+		p |= 01000
+	}
+	return p
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+// makeDigestTree creates a/, a/x.txt ("hello"), b.txt (empty) and, if possible, l -> b.txt.
+func makeDigestTree(t *testing.T) (root string, haveLink bool) {
+	t.Helper()
+	root = makeTree(t)
+	if err := os.Mkdir(filepath.Join(root, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a", "x.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "b.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	haveLink = os.Symlink("b.txt", filepath.Join(root, "l")) == nil
+	return
+}
+
+func digest(t *testing.T, root string, seed uint64, modes bool) uint64 {
+	t.Helper()
+	h, err := treeDigest(newSema(4), &walker{}, root, seed, modes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestTreeEncoding(t *testing.T) {
+	root, haveLink := makeDigestTree(t)
+	if !haveLink {
+		t.Skip("symlinks aren't supported")
+	}
+
+	le64 := func(b []byte, v uint64) []byte {
+		var a [8]byte
+		binary.LittleEndian.PutUint64(a[:], v)
+		return append(b, a[:]...)
+	}
+	str := func(b []byte, s string) []byte { return append(le64(b, uint64(len(s))), s...) }
+
+	// the documented encoding, written out by hand.
+	b := []byte("xxhsum-tree\x00\x01\x00")
+	b = str(append(b, 'd'), "a")
+	b = str(append(b, 'f'), "a/x.txt")
+	b = le64(le64(b, 5), xxhash.ChecksumString64S("hello", 7))
+	b = str(append(b, 'f'), "b.txt")
+	b = le64(le64(b, 0), xxhash.Checksum64S(nil, 7))
+	b = str(append(b, 'l'), "l")
+	b = str(b, "b.txt")
+
+	if got, want := digest(t, root, 7, false), xxhash.Checksum64S(b, 7); got != want {
+		t.Fatalf("got %#x; want %#x", got, want)
+	}
+	if got, want := digest(t, root, 0, false), uint64(0x6cafba96898dfd2a); got != want {
+		t.Fatalf("golden digest: got %#x; want %#x", got, want)
+	}
+}
+
+func TestTreeDigestChanges(t *testing.T) {
+	root, _ := makeDigestTree(t)
+	base := digest(t, root, 0, false)
+	withModes := digest(t, root, 0, true)
+	if base == withModes {
+		t.Fatal("--tree-modes should change the digest")
+	}
+
+	x := filepath.Join(root, "a", "x.txt")
+	if err := os.Chmod(x, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if digest(t, root, 0, false) != base {
+		t.Fatal("modes shouldn't matter without --tree-modes")
+	}
+	if digest(t, root, 0, true) == withModes {
+		t.Fatal("a mode change should change the digest with --tree-modes")
+	}
+
+	if err := os.WriteFile(x, []byte("hellO"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	changed := digest(t, root, 0, false)
+	if changed == base {
+		t.Fatal("a content change should change the digest")
+	}
+
+	if err := os.Rename(x, filepath.Join(root, "a", "y.txt")); err != nil {
+		t.Fatal(err)
+	}
+	renamed := digest(t, root, 0, false)
+	if renamed == changed {
+		t.Fatal("a rename should change the digest")
+	}
+
+	if err := os.Mkdir(filepath.Join(root, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if digest(t, root, 0, false) == renamed {
+		t.Fatal("an empty directory should change the digest")
+	}
+}
+
+func TestTreeDigestHidden(t *testing.T) {
+	root := makeTree(t, "a.txt", ".hidden/b.txt")
+	base := digest(t, root, 0, false)
+
+	if err := os.WriteFile(filepath.Join(root, ".hidden", "b.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if digest(t, root, 0, false) == base {
+		t.Fatal("a change to a hidden file should change the digest")
+	}
+}
+
+func TestTreeDigestFiltered(t *testing.T) {
+	root := makeTree(t, "a.txt", "b.txt")
+	base := digest(t, root, 0, false)
+
+	// nothing is excluded, but the digest records that a filter was used.
+	h, err := treeDigest(newSema(4), &walker{exclude: []string{"*.go"}}, root, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h == base {
+		t.Fatal("a filtered digest should differ from an unfiltered one")
+	}
+}
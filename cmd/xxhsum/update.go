@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+// racyWindow is how close to the start of an update an mtime may be before the file isn't cached,
+// a file written again within the file system's timestamp granularity could otherwise keep a stale checksum.
+const racyWindow = 2 * time.Second
+
+// cacheRecord is what update remembers about a file so it can skip rehashing it.
+type cacheRecord struct {
+	size  int64
+	mtime int64 // UnixNano
+	e     entry
+}
+
+// format returns the cache line for r, `size mtime seed hex  name`, the name is escaped like in a manifest.
+func (r cacheRecord) format() string {
+	return fmt.Sprintf("%d %d %d %s", r.size, r.mtime, r.e.seed, r.e.format(false))
+}
+
+// readCache returns the records in the cache file fn, a missing file is an empty cache.
+func readCache(fn string) (map[string]cacheRecord, error) {
+	recs := map[string]cacheRecord{}
+	f, err := os.Open(fn)
+	if os.IsNotExist(err) {
+		return recs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		// a malformed record only costs a rehash, so it's skipped.
+		parts := strings.SplitN(sc.Text(), " ", 4)
+		if len(parts) != 4 {
+			continue
+		}
+		var (
+			r    cacheRecord
+			seed uint64
+			err  error
+		)
+		if r.size, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+			continue
+		}
+		if r.mtime, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			continue
+		}
+		if seed, err = strconv.ParseUint(parts[2], 10, 64); err != nil {
+			continue
+		}
+		e, ok, _ := newParser(seed).parse(parts[3])
+		if !ok {
+			continue
+		}
+		r.e = e
+		recs[e.name] = r
+	}
+	return recs, sc.Err()
+}
+
+// updateStats counts what update did.
+type updateStats struct {
+	unchanged, updated, removed, rehashed int
+}
+
+// update rehashes the files in the manifest fn whose size or mtime changed since they were recorded in the cache file,
+// drops missing files and rewrites both files.
+func update(sema *sema, fn, cacheFn string) (st updateStats, err error) {
+	start := time.Now()
+
+	entries, malformed, err := readManifest(fn)
+	if err != nil {
+		return st, err
+	}
+	if malformed > 0 {
+		return st, fmt.Errorf("%s: %d improperly formatted lines, not rewriting it", displayName(fn), malformed)
+	}
+
+	cache, err := readCache(cacheFn)
+	if err != nil {
+		return st, err
+	}
+
+	var (
+		o    = newOrderer(sema, reorderWindow(), false)
+		keep = make([]bool, len(entries))
+		recs = make([]cacheRecord, len(entries))
+		errs []error
+	)
+	for i := range entries {
+		i, e := i, &entries[i]
+		o.Run(func() func() {
+			rec, rehashed, err := refresh(*e, cache[e.name])
+			return func() {
+				switch {
+				case os.IsNotExist(err):
+					st.removed++
+					if !*statusArg {
+						printf("removed  %s", displayName(e.name))
+					}
+					return
+				case err != nil:
+					errs = append(errs, err)
+					keep[i] = true // keep the old line, it may be a temporary error
+					return
+				}
+
+				keep[i], recs[i] = true, rec
+				if rehashed {
+					st.rehashed++
+				}
+				if rec.e.sum != e.sum {
+					st.updated++
+					if !*statusArg {
+						printf("updated  %s", displayName(e.name))
+					}
+					e.sum = rec.e.sum
+				} else {
+					st.unchanged++
+				}
+			}
+		})
+	}
+	o.Wait()
+
+	var (
+		kept    []entry
+		records []string
+	)
+	for i, e := range entries {
+		if !keep[i] {
+			continue
+		}
+		kept = append(kept, e)
+		if r := recs[i]; r.mtime != 0 && time.Unix(0, r.mtime).Before(start.Add(-racyWindow)) {
+			records = append(records, r.format())
+		}
+	}
+
+	if err := writeFileAtomic(fn, formatManifest(kept), recordEnd()); err != nil {
+		return st, err
+	}
+	if err := writeFileAtomic(cacheFn, records, '\n'); err != nil {
+		return st, err
+	}
+	if len(errs) > 0 {
+		return st, errs[0]
+	}
+	return st, nil
+}
+
+// formatManifest returns the lines of a manifest listing entries, each in the style it was read in.
+// Legacy entries keep the `# seed` and `# 32bit` / `# 64bit` headers they need, GNU and BSD lines can't record a seed.
+func formatManifest(entries []entry) (lines []string) {
+	var (
+		seeded bool
+		algo   xxhash.Algorithm // of the last algorithm header
+	)
+	for _, e := range entries {
+		switch {
+		case !e.legacy && *zeroArg:
+			lines = append(lines, e.formatRaw(e.tag))
+			continue
+		case !e.legacy:
+			lines = append(lines, e.format(e.tag))
+			continue
+		case !seeded:
+			lines = append(lines, fmt.Sprintf("# seed %d", e.seed))
+			seeded = true
+		}
+		if e.algo != algo {
+			if e.algo == xxhash.XXH32 {
+				lines = append(lines, "# 32bit")
+			} else {
+				lines = append(lines, "# 64bit")
+			}
+			algo = e.algo
+		}
+		lines = append(lines, e.formatLegacy())
+	}
+	return lines
+}
+
+// refresh returns the current record for e, reusing the cached checksum if the file's size and mtime didn't change.
+func refresh(e entry, cached cacheRecord) (rec cacheRecord, rehashed bool, err error) {
+	fi, err := os.Stat(e.name)
+	if err != nil {
+		return rec, false, err
+	}
+	if e.tree {
+		// a directory's mtime doesn't change with the files in it, tree digests aren't cached.
+		rec.e = e
+		rec.e.sum, _, err = hashEntry(e)
+		return rec, true, err
+	}
+	rec = cacheRecord{size: fi.Size(), mtime: fi.ModTime().UnixNano(), e: e}
+
+	if c := cached.e; c.name == e.name && c.algo == e.algo && c.seed == e.seed &&
+		cached.size == rec.size && cached.mtime == rec.mtime {
+		rec.e.sum = c.sum
+		return rec, false, nil
+	}
+
+	if rec.e.sum, err = hashFile(e.name, e.algo, e.seed); err != nil {
+		return rec, true, err
+	}
+	return rec, true, nil
+}
+
+// writeFileAtomic replaces fn with lines, each followed by end, through a temporary file in the same directory.
+func writeFileAtomic(fn string, lines []string, end byte) error {
+	f, err := os.CreateTemp(filepath.Dir(fn), "."+filepath.Base(fn)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	for _, ln := range lines {
+		io.WriteString(w, ln)
+		w.WriteByte(end)
+	}
+	if err = w.Flush(); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if fi, err := os.Stat(fn); err == nil {
+		os.Chmod(f.Name(), fi.Mode().Perm())
+	} else {
+		os.Chmod(f.Name(), 0644)
+	}
+	return os.Rename(f.Name(), fn)
+}
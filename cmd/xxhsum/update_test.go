@@ -0,0 +1,153 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+func TestUpdate(t *testing.T) {
+	defer func(s bool) { *statusArg = s }(*statusArg)
+	*statusArg = true
+
+	var (
+		dir = t.TempDir()
+		old = time.Now().Add(-time.Hour)
+	)
+	write := func(name, data string, mt time.Time) string {
+		fn := filepath.Join(dir, name)
+		if err := os.WriteFile(fn, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(fn, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+		return fn
+	}
+
+	var (
+		a = write("a", "a", old)
+		b = write("b", "b", old)
+		c = write("c", "c", old)
+
+		manifest = filepath.Join(dir, "sums.xx")
+		cacheFn  = manifest + ".cache"
+	)
+	lines := []string{
+		entry{name: a, sum: xxhash.ChecksumString64("a"), algo: xxhash.XXH64}.format(false),
+		entry{name: b, sum: xxhash.ChecksumString64("b"), algo: xxhash.XXH64}.format(true),
+		entry{name: c, sum: 0, algo: xxhash.XXH64}.format(false), // stale
+	}
+	if err := os.WriteFile(manifest, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first run has no cache, so it hashes everything.
+	st, err := update(newSema(2), manifest, cacheFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (updateStats{unchanged: 2, updated: 1, rehashed: 3}); st != want {
+		t.Fatalf("got %+v; want %+v", st, want)
+	}
+
+	// change b without changing its mtime or size, update trusts the cache and doesn't notice.
+	write("b", "B", old)
+	// change a's contents and mtime, and remove c.
+	write("a", "A", time.Now())
+	os.Remove(c)
+
+	st, err = update(newSema(2), manifest, cacheFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (updateStats{unchanged: 1, updated: 1, removed: 1, rehashed: 1}); st != want {
+		t.Fatalf("got %+v; want %+v", st, want)
+	}
+
+	got, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := entry{name: a, sum: xxhash.ChecksumString64("A"), algo: xxhash.XXH64}.format(false) + "\n" + lines[1] + "\n"
+	if string(got) != want {
+		t.Fatalf("got manifest %q; want %q", got, want)
+	}
+
+	// a was modified just now, so it must not be cached and is rehashed again.
+	st, err = update(newSema(2), manifest, cacheFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (updateStats{unchanged: 2, rehashed: 1}); st != want {
+		t.Fatalf("got %+v; want %+v", st, want)
+	}
+}
+
+func TestUpdateRefusesMalformed(t *testing.T) {
+	manifest := filepath.Join(t.TempDir(), "sums\n.xx")
+	if err := os.WriteFile(manifest, []byte("garbage\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(s bool) { *statusArg = s }(*statusArg)
+	*statusArg = true
+
+	_, err := update(newSema(1), manifest, manifest+".cache")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if strings.Contains(err.Error(), "\n") {
+		t.Fatalf("the error has a raw newline: %q", err)
+	}
+	if got, _ := os.ReadFile(manifest); string(got) != "garbage\n" {
+		t.Fatalf("the manifest was rewritten: %q", got)
+	}
+}
+
+func TestUpdateKeepsLegacyHeaders(t *testing.T) {
+	defer func(s bool, seed uint64) { *statusArg, *seedArg = s, seed }(*statusArg, *seedArg)
+	*statusArg = true
+
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	for _, fn := range []string{a, b} {
+		if err := os.WriteFile(fn, []byte(fn), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest := filepath.Join(dir, "old.xx")
+	lines := []string{
+		"# seed 7",
+		"# 32bit",
+		entry{name: a, sum: uint64(xxhash.ChecksumString32S(a, 7)), algo: xxhash.XXH32}.formatLegacy(),
+		"# 64bit",
+		entry{name: b, sum: 0, algo: xxhash.XXH64}.formatLegacy(), // stale
+	}
+	if err := os.WriteFile(manifest, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// legacy entries carry their seed, so update doesn't need -s 7.
+	*seedArg = 0
+	if _, err := update(newSema(2), manifest, manifest+".cache"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines[4] = entry{name: b, sum: xxhash.ChecksumString64S(b, 7), algo: xxhash.XXH64}.formatLegacy()
+	if want := strings.Join(lines, "\n") + "\n"; string(got) != want {
+		t.Fatalf("got manifest %q; want %q", got, want)
+	}
+
+	// the manifest still verifies without -s.
+	if st := verify(newSema(2), strings.NewReader(string(got)), manifest); st != (checkStats{ok: 2}) {
+		t.Fatalf("got %+v", st)
+	}
+}
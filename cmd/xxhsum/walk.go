@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// stringList is a flag.Value that collects every occurrence of a repeated flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// walker lists the files under a directory for -r and --tree.
+type walker struct {
+	include, exclude []string // glob patterns matched against the base name and the path relative to the root
+	followSymlinks   bool
+	oneFileSystem    bool // unix only
+	hidden           bool
+
+	onError func(err error)
+}
+
+// walkEntry is a file, directory, symlink or special file found by walker.entries.
+type walkEntry struct {
+	path string
+	rel  string // slash separated path relative to the root
+	mode os.FileMode
+}
+
+// walk returns the regular files under root sorted by path.
+func (w *walker) walk(root string) []string {
+	var files []string
+	for _, e := range w.entries(root) {
+		if e.mode.IsRegular() {
+			files = append(files, e.path)
+		}
+	}
+	return files
+}
+
+// entries returns everything under root, excluding root itself, sorted by relative path.
+// Only the type bits of each mode are set, symlinks are only returned if they aren't followed.
+func (w *walker) entries(root string) []walkEntry {
+	var (
+		entries []walkEntry
+		active  = map[string]bool{} // real paths of the directories being walked, root first
+		dev, _  = deviceOf(root)
+	)
+
+	var walkDir func(dir, rel string)
+	walkDir = func(dir, rel string) {
+		if w.followSymlinks {
+			// a symlink loop leads back into a directory that is still being walked,
+			// other links to a directory are walked again under their own path.
+			real, err := filepath.EvalSymlinks(dir)
+			if err != nil {
+				w.onError(err)
+				return
+			}
+			if active[real] {
+				return
+			}
+			active[real] = true
+			defer delete(active, real)
+		}
+
+		des, err := os.ReadDir(dir)
+		if err != nil {
+			w.onError(err)
+		}
+		for _, de := range des {
+			var (
+				name = de.Name()
+				fn   = filepath.Join(dir, name)
+				rfn  = filepath.ToSlash(filepath.Join(rel, name))
+				mode = de.Type()
+			)
+
+			if !w.hidden && strings.HasPrefix(name, ".") {
+				continue
+			}
+			if matchAny(w.exclude, name, rfn) {
+				continue
+			}
+
+			if mode&os.ModeSymlink != 0 && w.followSymlinks {
+				fi, err := os.Stat(fn)
+				if err != nil {
+					w.onError(err)
+					continue
+				}
+				mode = fi.Mode().Type()
+			}
+
+			if mode.IsDir() {
+				if w.oneFileSystem {
+					if d, ok := deviceOf(fn); ok && d != dev {
+						continue
+					}
+				}
+				entries = append(entries, walkEntry{fn, rfn, mode})
+				walkDir(fn, rfn)
+				continue
+			}
+
+			if len(w.include) == 0 || matchAny(w.include, name, rfn) {
+				entries = append(entries, walkEntry{fn, rfn, mode})
+			}
+		}
+	}
+	walkDir(root, "")
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].rel < entries[j].rel })
+	return entries
+}
+
+func matchAny(patterns []string, name, rel string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(p, rel); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,7 @@
+//go:build windows || plan9 || js || wasip1
+// +build windows plan9 js wasip1
+
+package main
+
+// deviceOf isn't available on this platform, so --one-file-system has no effect.
+func deviceOf(fn string) (uint64, bool) { return 0, false }
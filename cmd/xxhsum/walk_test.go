@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func makeTree(t *testing.T, files ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, fn := range files {
+		fn = filepath.Join(dir, filepath.FromSlash(fn))
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(fn), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func relAll(t *testing.T, root string, files []string) []string {
+	t.Helper()
+	out := make([]string, 0, len(files))
+	for _, fn := range files {
+		rel, err := filepath.Rel(root, fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, filepath.ToSlash(rel))
+	}
+	return out
+}
+
+func TestWalk(t *testing.T) {
+	root := makeTree(t,
+		"b.go", "a.txt", "a-b/x.go", "a/z.go", "a/y.txt",
+		".git/config", "a/.hidden.go", "vendor/v.go",
+	)
+
+	for _, c := range []struct {
+		w    walker
+		want []string
+	}{
+		{walker{}, []string{"a-b/x.go", "a.txt", "a/y.txt", "a/z.go", "b.go", "vendor/v.go"}},
+		{walker{hidden: true}, []string{".git/config", "a-b/x.go", "a.txt", "a/.hidden.go", "a/y.txt", "a/z.go", "b.go", "vendor/v.go"}},
+		{walker{include: []string{"*.go"}}, []string{"a-b/x.go", "a/z.go", "b.go", "vendor/v.go"}},
+		{walker{include: []string{"*.go"}, exclude: []string{"vendor"}}, []string{"a-b/x.go", "a/z.go", "b.go"}},
+		{walker{exclude: []string{"a/*.txt", "a-b"}}, []string{"a.txt", "a/z.go", "b.go", "vendor/v.go"}},
+	} {
+		c.w.onError = func(err error) { t.Error(err) }
+		if got := relAll(t, root, c.w.walk(root)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%+v: got %q; want %q", c.w, got, c.want)
+		}
+	}
+}
+
+func TestWalkSymlinks(t *testing.T) {
+	root := makeTree(t, "d/f.txt", "g.txt")
+	if err := os.Symlink(filepath.Join(root, "d"), filepath.Join(root, "d/loop")); err != nil {
+		t.Skip("symlinks aren't supported:", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "g.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	// a second path to d that isn't a loop.
+	if err := os.Symlink(filepath.Join(root, "d"), filepath.Join(root, "e")); err != nil {
+		t.Fatal(err)
+	}
+
+	w := walker{onError: func(err error) { t.Error(err) }}
+	if got, want := relAll(t, root, w.walk(root)), []string{"d/f.txt", "g.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	w.followSymlinks = true
+	if got, want := relAll(t, root, w.walk(root)), []string{"d/f.txt", "e/f.txt", "g.txt", "link.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
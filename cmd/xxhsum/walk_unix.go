@@ -0,0 +1,22 @@
+//go:build !windows && !plan9 && !js && !wasip1
+// +build !windows,!plan9,!js,!wasip1
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// deviceOf returns the id of the device fn is on, for --one-file-system.
+func deviceOf(fn string) (uint64, bool) {
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return 0, false
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
@@ -0,0 +1,370 @@
+// Package cuckoo implements a cuckoo filter backed by xxhash.
+//
+// A cuckoo filter is an approximate set membership structure like a Bloom filter,
+// but it supports deleting items that were previously inserted.
+// Both the bucket index and the fingerprint of a key are derived from a single XXH64 checksum.
+package cuckoo
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+const (
+	// DefaultFingerprintBits is the fingerprint size used when Options.FingerprintBits is 0.
+	DefaultFingerprintBits = 16
+	// DefaultBucketSize is the bucket size used when Options.BucketSize is 0.
+	DefaultBucketSize = 4
+	// DefaultMaxKicks is the eviction limit used when Options.MaxKicks is 0.
+	DefaultMaxKicks = 500
+
+	maxFingerprintBits = 32
+	maxBucketSize      = 8
+
+	// used to derive the alternate bucket from a fingerprint, same constant as the reference implementation.
+	altMul = 0x5bd1e995
+
+	magic         = "xxcf\x01"
+	headerSize    = len(magic) + 1 + 1 + 4 + 8 + 8 + 8
+	maxLoadFactor = 0.96
+)
+
+var (
+	// ErrFilterFull is returned by Insert when a key can't be placed within the eviction limit.
+	// The filter is left unchanged when it is returned.
+	ErrFilterFull = errors.New("cuckoo: filter is full")
+
+	// ErrInvalidOptions is returned by New when the options are out of range.
+	ErrInvalidOptions = errors.New("cuckoo: invalid options")
+)
+
+// Options configures a Filter, zero values select the defaults.
+type Options struct {
+	// FingerprintBits is the number of bits stored per item, 2 to 32.
+	// The false positive rate is roughly 2*BucketSize / 2^FingerprintBits.
+	FingerprintBits int
+
+	// BucketSize is the number of fingerprints per bucket, 1 to 8.
+	BucketSize int
+
+	// MaxKicks is the number of evictions Insert attempts before giving up.
+	MaxKicks int
+
+	// Seed is passed to xxhash.Checksum64S.
+	Seed uint64
+}
+
+// Filter is a cuckoo filter, it is not safe for concurrent use.
+type Filter struct {
+	table []uint64 // packed fingerprints, 0 means empty
+
+	seed     uint64
+	mask     uint64 // numBuckets - 1
+	count    uint64
+	rnd      uint64 // xorshift state for picking eviction victims
+	maxKicks uint32
+	fpBits   uint8
+	bktSize  uint8
+}
+
+// New returns a filter that can hold at least capacity items.
+func New(capacity uint64, opts Options) (*Filter, error) {
+	if opts.FingerprintBits == 0 {
+		opts.FingerprintBits = DefaultFingerprintBits
+	}
+	if opts.BucketSize == 0 {
+		opts.BucketSize = DefaultBucketSize
+	}
+	if opts.MaxKicks == 0 {
+		opts.MaxKicks = DefaultMaxKicks
+	}
+
+	if opts.FingerprintBits < 2 || opts.FingerprintBits > maxFingerprintBits ||
+		opts.BucketSize < 1 || opts.BucketSize > maxBucketSize || opts.MaxKicks < 0 {
+		return nil, ErrInvalidOptions
+	}
+
+	bs := uint64(opts.BucketSize)
+	nb := nextPow2((capacity + bs - 1) / bs)
+	if float64(capacity)/float64(nb*bs) > maxLoadFactor {
+		nb <<= 1
+	}
+
+	f := &Filter{
+		seed:     opts.Seed,
+		mask:     nb - 1,
+		maxKicks: uint32(opts.MaxKicks),
+		fpBits:   uint8(opts.FingerprintBits),
+		bktSize:  uint8(opts.BucketSize),
+	}
+	f.table = make([]uint64, tableWords(nb*bs, f.fpBits))
+	f.resetRand()
+	return f, nil
+}
+
+// Insert adds key to the filter.
+func (f *Filter) Insert(key []byte) error {
+	return f.insert(xxhash.Checksum64S(key, f.seed))
+}
+
+// InsertString is like Insert but hashes s without copying it.
+func (f *Filter) InsertString(s string) error {
+	return f.insert(xxhash.ChecksumString64S(s, f.seed))
+}
+
+// Lookup reports whether key may be in the filter.
+func (f *Filter) Lookup(key []byte) bool {
+	return f.lookup(xxhash.Checksum64S(key, f.seed))
+}
+
+// LookupString is like Lookup but hashes s without copying it.
+func (f *Filter) LookupString(s string) bool {
+	return f.lookup(xxhash.ChecksumString64S(s, f.seed))
+}
+
+// Delete removes one copy of key from the filter and reports whether it was found.
+// Deleting a key that was never inserted may remove a different key with the same fingerprint.
+func (f *Filter) Delete(key []byte) bool {
+	return f.delete(xxhash.Checksum64S(key, f.seed))
+}
+
+// DeleteString is like Delete but hashes s without copying it.
+func (f *Filter) DeleteString(s string) bool {
+	return f.delete(xxhash.ChecksumString64S(s, f.seed))
+}
+
+// Count returns the number of items in the filter.
+func (f *Filter) Count() uint64 { return f.count }
+
+// Cap returns the total number of fingerprint slots.
+func (f *Filter) Cap() uint64 { return f.numBuckets() * uint64(f.bktSize) }
+
+// LoadFactor returns Count() / Cap().
+func (f *Filter) LoadFactor() float64 { return float64(f.count) / float64(f.Cap()) }
+
+// Reset removes all items from the filter.
+func (f *Filter) Reset() {
+	for i := range f.table {
+		f.table[i] = 0
+	}
+	f.count = 0
+	f.resetRand()
+}
+
+func (f *Filter) insert(h uint64) error {
+	i1, fp := f.indexAndFingerprint(h)
+	if f.insertInto(i1, fp) {
+		return nil
+	}
+	i2 := f.altIndex(i1, fp)
+	if f.insertInto(i2, fp) {
+		return nil
+	}
+
+	type move struct {
+		slot uint64
+		fp   uint32
+	}
+
+	var (
+		path = make([]move, 0, 16)
+		bs   = uint64(f.bktSize)
+		i    = i1
+	)
+
+	if f.next()&1 == 1 {
+		i = i2
+	}
+
+	for k := uint32(0); k < f.maxKicks; k++ {
+		slot := i*bs + f.next()%bs
+		old := f.get(slot)
+		f.set(slot, fp)
+		path = append(path, move{slot, old})
+
+		fp, i = old, f.altIndex(i, old)
+		if f.insertInto(i, fp) {
+			return nil
+		}
+	}
+
+	// undo the evictions so a failed insert doesn't drop an existing item.
+	for j := len(path) - 1; j >= 0; j-- {
+		f.set(path[j].slot, path[j].fp)
+	}
+
+	return ErrFilterFull
+}
+
+func (f *Filter) insertInto(i uint64, fp uint32) bool {
+	bs := uint64(f.bktSize)
+	for s := i * bs; s < (i+1)*bs; s++ {
+		if f.get(s) == 0 {
+			f.set(s, fp)
+			f.count++
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Filter) lookup(h uint64) bool {
+	i1, fp := f.indexAndFingerprint(h)
+	return f.find(i1, fp) >= 0 || f.find(f.altIndex(i1, fp), fp) >= 0
+}
+
+func (f *Filter) delete(h uint64) bool {
+	i1, fp := f.indexAndFingerprint(h)
+	s := f.find(i1, fp)
+	if s < 0 {
+		if s = f.find(f.altIndex(i1, fp), fp); s < 0 {
+			return false
+		}
+	}
+	f.set(uint64(s), 0)
+	f.count--
+	return true
+}
+
+func (f *Filter) find(i uint64, fp uint32) int64 {
+	bs := uint64(f.bktSize)
+	for s := i * bs; s < (i+1)*bs; s++ {
+		if f.get(s) == fp {
+			return int64(s)
+		}
+	}
+	return -1
+}
+
+// indexAndFingerprint uses the low bits of h for the bucket and the high 32 bits for the fingerprint.
+func (f *Filter) indexAndFingerprint(h uint64) (uint64, uint32) {
+	fp := uint32(h>>32) & f.fpMask()
+	if fp == 0 {
+		fp = 1
+	}
+	return h & f.mask, fp
+}
+
+func (f *Filter) altIndex(i uint64, fp uint32) uint64 {
+	return (i ^ uint64(fp)*altMul) & f.mask
+}
+
+func (f *Filter) fpMask() uint32     { return uint32(uint64(1)<<f.fpBits - 1) }
+func (f *Filter) numBuckets() uint64 { return f.mask + 1 }
+
+func (f *Filter) get(slot uint64) uint32 {
+	pos := slot * uint64(f.fpBits)
+	w, off := pos>>6, pos&63
+	v := f.table[w] >> off
+	if off+uint64(f.fpBits) > 64 {
+		v |= f.table[w+1] << (64 - off)
+	}
+	return uint32(v) & f.fpMask()
+}
+
+func (f *Filter) set(slot uint64, fp uint32) {
+	var (
+		pos    = slot * uint64(f.fpBits)
+		w, off = pos >> 6, pos & 63
+		m      = uint64(f.fpMask())
+	)
+	f.table[w] = f.table[w]&^(m<<off) | uint64(fp)<<off
+	if off+uint64(f.fpBits) > 64 {
+		sh := 64 - off
+		f.table[w+1] = f.table[w+1]&^(m>>sh) | uint64(fp)>>sh
+	}
+}
+
+func (f *Filter) resetRand() { f.rnd = f.seed ^ 0x9e3779b97f4a7c15 }
+
+func (f *Filter) next() uint64 {
+	x := f.rnd
+	x ^= x << 13
+	x ^= x >> 7
+	x ^= x << 17
+	f.rnd = x
+	return x
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+//
+// The format is a magic header, the fingerprint bits, bucket size, max kicks, seed,
+// number of buckets and item count, followed by the packed table, all little endian.
+func (f *Filter) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, headerSize+len(f.table)*8)
+	b = append(b, magic...)
+	b = append(b, f.fpBits, f.bktSize)
+	b = appendUint32(b, f.maxKicks)
+	b = appendUint64(b, f.seed)
+	b = appendUint64(b, f.numBuckets())
+	b = appendUint64(b, f.count)
+	for _, w := range f.table {
+		b = appendUint64(b, w)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (f *Filter) UnmarshalBinary(b []byte) error {
+	if len(b) < len(magic) || string(b[:len(magic)]) != magic {
+		return errors.New("cuckoo: invalid filter identifier")
+	}
+	if len(b) < headerSize {
+		return errors.New("cuckoo: invalid filter size")
+	}
+	b = b[len(magic):]
+
+	var nf Filter
+	nf.fpBits, nf.bktSize = b[0], b[1]
+	nf.maxKicks = binary.LittleEndian.Uint32(b[2:])
+	nf.seed = binary.LittleEndian.Uint64(b[6:])
+	nb := binary.LittleEndian.Uint64(b[14:])
+	nf.count = binary.LittleEndian.Uint64(b[22:])
+	b = b[30:]
+
+	if nf.fpBits < 2 || nf.fpBits > maxFingerprintBits || nf.bktSize < 1 || nf.bktSize > maxBucketSize ||
+		nb == 0 || nb&(nb-1) != 0 || nb > uint64(len(b))*4 {
+		return errors.New("cuckoo: invalid filter parameters")
+	}
+
+	words := tableWords(nb*uint64(nf.bktSize), nf.fpBits)
+	if uint64(len(b)) != words*8 {
+		return errors.New("cuckoo: invalid filter size")
+	}
+
+	nf.mask = nb - 1
+	nf.table = make([]uint64, words)
+	for i := range nf.table {
+		nf.table[i] = binary.LittleEndian.Uint64(b[i*8:])
+	}
+	nf.resetRand()
+
+	*f = nf
+	return nil
+}
+
+func appendUint32(b []byte, x uint32) []byte {
+	var a [4]byte
+	binary.LittleEndian.PutUint32(a[:], x)
+	return append(b, a[:]...)
+}
+
+func appendUint64(b []byte, x uint64) []byte {
+	var a [8]byte
+	binary.LittleEndian.PutUint64(a[:], x)
+	return append(b, a[:]...)
+}
+
+func tableWords(slots uint64, fpBits uint8) uint64 {
+	return (slots*uint64(fpBits) + 63) / 64
+}
+
+func nextPow2(v uint64) uint64 {
+	n := uint64(1)
+	for n < v {
+		n <<= 1
+	}
+	return n
+}
@@ -0,0 +1,217 @@
+package cuckoo_test
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/OneOfOne/xxhash"
+	"github.com/OneOfOne/xxhash/cuckoo"
+)
+
+func TestInsertLookupDelete(t *testing.T) {
+	for _, bits := range []int{7, 12, 16, 32} {
+		t.Run(strconv.Itoa(bits), func(t *testing.T) {
+			const N = 10000
+			f, err := cuckoo.New(N, cuckoo.Options{FingerprintBits: bits})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < N; i++ {
+				if err := f.InsertString("key-" + strconv.Itoa(i)); err != nil {
+					t.Fatalf("insert %d: %v", i, err)
+				}
+			}
+			if f.Count() != N {
+				t.Fatalf("expected %d items, got %d", N, f.Count())
+			}
+			for i := 0; i < N; i++ {
+				if !f.Lookup([]byte("key-" + strconv.Itoa(i))) {
+					t.Fatalf("false negative for %d", i)
+				}
+			}
+			for i := 0; i < N; i += 2 {
+				if !f.DeleteString("key-" + strconv.Itoa(i)) {
+					t.Fatalf("delete %d failed", i)
+				}
+			}
+			if f.Count() != N/2 {
+				t.Fatalf("expected %d items, got %d", N/2, f.Count())
+			}
+			for i := 1; i < N; i += 2 {
+				if !f.LookupString("key-" + strconv.Itoa(i)) {
+					t.Fatalf("false negative for %d after deletes", i)
+				}
+			}
+		})
+	}
+}
+
+func TestFalsePositiveRate(t *testing.T) {
+	const N = 50000
+	f, _ := cuckoo.New(N, cuckoo.Options{})
+	for i := 0; i < N; i++ {
+		f.InsertString("in-" + strconv.Itoa(i))
+	}
+	var fp int
+	for i := 0; i < N; i++ {
+		if f.LookupString("out-" + strconv.Itoa(i)) {
+			fp++
+		}
+	}
+	// 2*4/2^16 ~= 0.00012, allow plenty of slack.
+	if rate := float64(fp) / N; rate > 0.001 {
+		t.Fatalf("false positive rate too high: %f", rate)
+	}
+}
+
+func TestFilterFull(t *testing.T) {
+	f, _ := cuckoo.New(64, cuckoo.Options{MaxKicks: 50})
+	var (
+		inserted []string
+		full     bool
+	)
+	for i := 0; i < 1000; i++ {
+		k := strconv.Itoa(i)
+		if err := f.InsertString(k); err != nil {
+			if err != cuckoo.ErrFilterFull {
+				t.Fatal(err)
+			}
+			full = true
+			break
+		}
+		inserted = append(inserted, k)
+	}
+	if !full {
+		t.Fatal("expected ErrFilterFull")
+	}
+	if f.Count() != uint64(len(inserted)) {
+		t.Fatalf("expected %d items, got %d", len(inserted), f.Count())
+	}
+	for _, k := range inserted {
+		if !f.LookupString(k) {
+			t.Fatalf("%q was lost after a failed insert", k)
+		}
+	}
+}
+
+func TestBinaryMarshaling(t *testing.T) {
+	f, _ := cuckoo.New(1000, cuckoo.Options{FingerprintBits: 13, BucketSize: 2, Seed: 42})
+	for i := 0; i < 800; i++ {
+		f.InsertString(strconv.Itoa(i))
+	}
+	b, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nf cuckoo.Filter
+	if err := nf.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if nf.Count() != f.Count() || nf.Cap() != f.Cap() {
+		t.Fatalf("count/cap mismatch: %d/%d vs %d/%d", nf.Count(), nf.Cap(), f.Count(), f.Cap())
+	}
+	for i := 0; i < 800; i++ {
+		if !nf.LookupString(strconv.Itoa(i)) {
+			t.Fatalf("false negative for %d after UnmarshalBinary", i)
+		}
+	}
+	if err := nf.UnmarshalBinary(b[:len(b)-1]); err == nil {
+		t.Fatal("expected an error for a truncated filter")
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	for _, o := range []cuckoo.Options{{FingerprintBits: 1}, {FingerprintBits: 33}, {BucketSize: 9}, {MaxKicks: -1}} {
+		if _, err := cuckoo.New(10, o); err != cuckoo.ErrInvalidOptions {
+			t.Fatalf("%+v: expected ErrInvalidOptions, got %v", o, err)
+		}
+	}
+}
+
+// bloom is a minimal Bloom filter used as a baseline in the benchmarks.
+type bloom struct {
+	bits []uint64
+	m, k uint64
+}
+
+func newBloom(n int, p float64) *bloom {
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	return &bloom{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+func (b *bloom) add(s string) {
+	h := xxhash.ChecksumString64(s)
+	h1, h2 := h, h>>32|h<<32
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % b.m
+		b.bits[bit>>6] |= 1 << (bit & 63)
+	}
+}
+
+func (b *bloom) has(s string) bool {
+	h := xxhash.ChecksumString64(s)
+	h1, h2 := h, h>>32|h<<32
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % b.m
+		if b.bits[bit>>6]&(1<<(bit&63)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+const benchN = 1 << 16
+
+var benchKeys = func() []string {
+	keys := make([]string, benchN)
+	for i := range keys {
+		keys[i] = "session-" + strconv.Itoa(i)
+	}
+	return keys
+}()
+
+// the default cuckoo filter has a false positive rate of about 2*4/2^16.
+const benchFPRate = 8.0 / (1 << 16)
+
+func BenchmarkInsert(b *testing.B) {
+	b.Run("Cuckoo", func(b *testing.B) {
+		f, _ := cuckoo.New(benchN, cuckoo.Options{})
+		for i := 0; i < b.N; i++ {
+			if i%benchN == 0 {
+				f.Reset()
+			}
+			f.InsertString(benchKeys[i%benchN])
+		}
+	})
+	b.Run("Bloom", func(b *testing.B) {
+		f := newBloom(benchN, benchFPRate)
+		for i := 0; i < b.N; i++ {
+			f.add(benchKeys[i%benchN])
+		}
+	})
+}
+
+func BenchmarkLookup(b *testing.B) {
+	b.Run("Cuckoo", func(b *testing.B) {
+		f, _ := cuckoo.New(benchN, cuckoo.Options{})
+		for _, k := range benchKeys {
+			f.InsertString(k)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			f.LookupString(benchKeys[i%benchN])
+		}
+	})
+	b.Run("Bloom", func(b *testing.B) {
+		f := newBloom(benchN, benchFPRate)
+		for _, k := range benchKeys {
+			f.add(k)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			f.has(benchKeys[i%benchN])
+		}
+	})
+}
@@ -0,0 +1,207 @@
+// Package hashmap implements a generic open addressing hash map keyed by XXH64.
+//
+// The map uses Robin Hood hashing with backward shift deletion, entries are stored inline in a
+// single slice, which keeps the per-entry overhead well below Go's builtin map for large maps.
+// Maps created with New use a random per-process seed so keys can't be chosen to collide.
+package hashmap
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+const (
+	minSlots = 8
+
+	// grow when count > len(slots) * maxLoadNum / maxLoadDen
+	maxLoadNum = 7
+	maxLoadDen = 8
+)
+
+var processSeed = func() uint64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("hashmap: can't read a random seed: " + err.Error())
+	}
+	return binary.LittleEndian.Uint64(b[:])
+}()
+
+// Hasher hashes keys of type K with a seed, xxhash.Hasher implementations such as
+// xxhash.IntHasher and xxhash.ComparableHasher satisfy it.
+type Hasher[K any] interface {
+	Hash(key K, seed uint64) uint64
+}
+
+// HasherFunc is an adapter to use an ordinary function as a Hasher.
+type HasherFunc[K any] func(key K, seed uint64) uint64
+
+// Hash calls f(key, seed).
+func (f HasherFunc[K]) Hash(key K, seed uint64) uint64 { return f(key, seed) }
+
+// StringHasher hashes strings with xxhash.ChecksumString64S.
+type StringHasher struct{}
+
+// Hash returns the XXH64 checksum of key.
+func (StringHasher) Hash(key string, seed uint64) uint64 { return xxhash.ChecksumString64S(key, seed) }
+
+type slot[K comparable, V any] struct {
+	key  K
+	dist uint32 // 1 + distance from the home slot, 0 means empty
+	val  V
+}
+
+// Map is a hash map from K to V, it is not safe for concurrent use.
+type Map[K comparable, V any] struct {
+	hasher Hasher[K]
+	seed   uint64
+	slots  []slot[K, V]
+	mask   uint64
+	count  int
+}
+
+// New returns a map with room for capacity entries, seeded with the per-process random seed.
+func New[K comparable, V any](h Hasher[K], capacity int) *Map[K, V] {
+	return NewS[K, V](h, capacity, processSeed)
+}
+
+// NewS returns a map with room for capacity entries that hashes keys with the specific seed.
+func NewS[K comparable, V any](h Hasher[K], capacity int, seed uint64) *Map[K, V] {
+	m := &Map[K, V]{hasher: h, seed: seed}
+	m.init(slotsFor(capacity))
+	return m
+}
+
+// NewString returns a string keyed map using StringHasher.
+func NewString[V any](capacity int) *Map[string, V] {
+	return New[string, V](StringHasher{}, capacity)
+}
+
+// Len returns the number of entries.
+func (m *Map[K, V]) Len() int { return m.count }
+
+// Get returns the value stored under key and whether it was found.
+func (m *Map[K, V]) Get(key K) (v V, ok bool) {
+	if i := m.find(key); i >= 0 {
+		return m.slots[i].val, true
+	}
+	return
+}
+
+// Set stores v under key, replacing any previous value.
+func (m *Map[K, V]) Set(key K, v V) {
+	h := m.hasher.Hash(key, m.seed)
+	if i := m.findHash(key, h); i >= 0 {
+		m.slots[i].val = v
+		return
+	}
+	if (m.count+1)*maxLoadDen > len(m.slots)*maxLoadNum {
+		m.grow()
+	}
+	m.insert(h, key, v)
+	m.count++
+}
+
+// Delete removes key and reports whether it was present.
+func (m *Map[K, V]) Delete(key K) bool {
+	i := m.find(key)
+	if i < 0 {
+		return false
+	}
+
+	// shift the following entries back until one is empty or already in its home slot.
+	for {
+		next := (uint64(i) + 1) & m.mask
+		if m.slots[next].dist <= 1 {
+			break
+		}
+		m.slots[i] = m.slots[next]
+		m.slots[i].dist--
+		i = int(next)
+	}
+	m.slots[i] = slot[K, V]{}
+	m.count--
+	return true
+}
+
+// Range calls fn for every entry until it returns false, the map must not be modified during Range.
+func (m *Map[K, V]) Range(fn func(key K, v V) bool) {
+	for i := range m.slots {
+		if s := &m.slots[i]; s.dist != 0 && !fn(s.key, s.val) {
+			return
+		}
+	}
+}
+
+// Clear removes all entries but keeps the allocated memory.
+func (m *Map[K, V]) Clear() {
+	for i := range m.slots {
+		m.slots[i] = slot[K, V]{}
+	}
+	m.count = 0
+}
+
+func (m *Map[K, V]) init(n int) {
+	m.slots = make([]slot[K, V], n)
+	m.mask = uint64(n - 1)
+}
+
+func (m *Map[K, V]) find(key K) int {
+	return m.findHash(key, m.hasher.Hash(key, m.seed))
+}
+
+func (m *Map[K, V]) findHash(key K, h uint64) int {
+	var (
+		i    = h & m.mask
+		dist = uint32(1)
+	)
+	for {
+		s := &m.slots[i]
+		// an entry closer to its home slot than we are to ours means key isn't here.
+		if s.dist < dist {
+			return -1
+		}
+		if s.dist == dist && s.key == key {
+			return int(i)
+		}
+		i, dist = (i+1)&m.mask, dist+1
+	}
+}
+
+func (m *Map[K, V]) insert(h uint64, key K, v V) {
+	var (
+		cur = slot[K, V]{key: key, dist: 1, val: v}
+		i   = h & m.mask
+	)
+	for {
+		s := &m.slots[i]
+		if s.dist == 0 {
+			*s = cur
+			return
+		}
+		// take the slot from entries that are closer to home and keep probing with theirs.
+		if s.dist < cur.dist {
+			*s, cur = cur, *s
+		}
+		i, cur.dist = (i+1)&m.mask, cur.dist+1
+	}
+}
+
+func (m *Map[K, V]) grow() {
+	old := m.slots
+	m.init(2 * len(old))
+	for i := range old {
+		if s := &old[i]; s.dist != 0 {
+			m.insert(m.hasher.Hash(s.key, m.seed), s.key, s.val)
+		}
+	}
+}
+
+func slotsFor(capacity int) int {
+	n := minSlots
+	for n*maxLoadNum < capacity*maxLoadDen {
+		n <<= 1
+	}
+	return n
+}
@@ -0,0 +1,169 @@
+package hashmap_test
+
+import (
+	"math/rand"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/OneOfOne/xxhash"
+	"github.com/OneOfOne/xxhash/hashmap"
+)
+
+func TestAgainstBuiltin(t *testing.T) {
+	var (
+		rnd  = rand.New(rand.NewSource(1))
+		m    = hashmap.NewString[int](0)
+		want = make(map[string]int)
+	)
+	for i := 0; i < 200000; i++ {
+		k := strconv.Itoa(rnd.Intn(5000))
+		switch rnd.Intn(3) {
+		case 0, 1:
+			m.Set(k, i)
+			want[k] = i
+		case 2:
+			_, ok := want[k]
+			if m.Delete(k) != ok {
+				t.Fatalf("Delete(%q) != %v", k, ok)
+			}
+			delete(want, k)
+		}
+	}
+
+	if m.Len() != len(want) {
+		t.Fatalf("expected %d entries, got %d", len(want), m.Len())
+	}
+	for k, v := range want {
+		if got, ok := m.Get(k); !ok || got != v {
+			t.Fatalf("Get(%q) = %d, %v; want %d", k, got, ok, v)
+		}
+	}
+	for i := 5000; i < 6000; i++ {
+		if _, ok := m.Get(strconv.Itoa(i)); ok {
+			t.Fatalf("found %d which was never added", i)
+		}
+	}
+
+	seen := 0
+	m.Range(func(k string, v int) bool {
+		if want[k] != v {
+			t.Fatalf("Range: %q = %d; want %d", k, v, want[k])
+		}
+		seen++
+		return true
+	})
+	if seen != len(want) {
+		t.Fatalf("Range visited %d entries; want %d", seen, len(want))
+	}
+
+	m.Clear()
+	if _, ok := m.Get("1"); ok || m.Len() != 0 {
+		t.Fatal("Clear didn't remove the entries")
+	}
+}
+
+func TestCustomHasher(t *testing.T) {
+	h := hashmap.HasherFunc[uint64](func(k, seed uint64) uint64 {
+		var b [8]byte
+		for i := range b {
+			b[i] = byte(k >> (8 * i))
+		}
+		return xxhash.Checksum64S(b[:], seed)
+	})
+	m := hashmap.NewS[uint64, string](h, 10, 42)
+	for i := uint64(0); i < 1000; i++ {
+		m.Set(i, strconv.FormatUint(i, 10))
+	}
+	for i := uint64(0); i < 1000; i++ {
+		if v, ok := m.Get(i); !ok || v != strconv.FormatUint(i, 10) {
+			t.Fatalf("Get(%d) = %q, %v", i, v, ok)
+		}
+	}
+}
+
+// collidingHasher sends every key to the same home slot to exercise long probe sequences.
+type collidingHasher struct{}
+
+func (collidingHasher) Hash(k int, _ uint64) uint64 { return uint64(k) << 32 }
+
+func TestCollisions(t *testing.T) {
+	m := hashmap.NewS[int, int](collidingHasher{}, 0, 0)
+	for i := 0; i < 100; i++ {
+		m.Set(i, i)
+	}
+	for i := 0; i < 100; i += 3 {
+		m.Delete(i)
+	}
+	for i := 0; i < 100; i++ {
+		v, ok := m.Get(i)
+		if want := i%3 != 0; ok != want || (ok && v != i) {
+			t.Fatalf("Get(%d) = %d, %v", i, v, ok)
+		}
+	}
+}
+
+const benchN = 1 << 20
+
+var benchKeys = func() []string {
+	keys := make([]string, benchN)
+	for i := range keys {
+		keys[i] = "user:" + strconv.Itoa(i*7919)
+	}
+	return keys
+}()
+
+func heapInUse() uint64 {
+	var ms runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&ms)
+	return ms.HeapInuse
+}
+
+func BenchmarkSet(b *testing.B) {
+	b.Run("Map", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			before := heapInUse()
+			m := hashmap.NewString[uint32](0)
+			for j, k := range benchKeys {
+				m.Set(k, uint32(j))
+			}
+			b.ReportMetric(float64(heapInUse()-before)/benchN, "B/entry")
+			runtime.KeepAlive(m)
+		}
+	})
+	b.Run("Builtin", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			before := heapInUse()
+			m := make(map[string]uint32)
+			for j, k := range benchKeys {
+				m[k] = uint32(j)
+			}
+			b.ReportMetric(float64(heapInUse()-before)/benchN, "B/entry")
+			runtime.KeepAlive(m)
+		}
+	})
+}
+
+func BenchmarkGet(b *testing.B) {
+	b.Run("Map", func(b *testing.B) {
+		m := hashmap.NewString[uint32](benchN)
+		for j, k := range benchKeys {
+			m.Set(k, uint32(j))
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			m.Get(benchKeys[i&(benchN-1)])
+		}
+	})
+	b.Run("Builtin", func(b *testing.B) {
+		m := make(map[string]uint32, benchN)
+		for j, k := range benchKeys {
+			m[k] = uint32(j)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = m[benchKeys[i&(benchN-1)]]
+		}
+	})
+}
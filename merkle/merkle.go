@@ -0,0 +1,338 @@
+// Package merkle builds binary Merkle trees over blocks of data, with inclusion proofs and tree diffs.
+//
+// Digests are 128bit, made of two XXH64 sums with independent seeds.
+// Leaves and interior nodes are domain separated by a one byte prefix:
+//
+//	leaf     = H(0x00 || block)
+//	interior = H(0x01 || left || right)
+//
+// A node without a sibling, the last one on a level with an odd number of nodes, is promoted
+// to the next level unchanged, so node i on level l always covers leaves [i<<l, (i+1)<<l).
+package merkle
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+
+	"github.com/OneOfOne/xxhash"
+	"github.com/OneOfOne/xxhash/cdc"
+)
+
+const (
+	leafPrefix     = 0x00
+	interiorPrefix = 0x01
+
+	seedHi = 0
+	seedLo = 0x9e3779b97f4a7c15
+
+	magic    = "xxmt\x01"
+	leafSize = 8 + 8 + DigestSize
+)
+
+// DigestSize is the size of a Digest in bytes.
+const DigestSize = 16
+
+// Digest is the hash of a node.
+type Digest [DigestSize]byte
+
+func (d Digest) String() string { return hex.EncodeToString(d[:]) }
+
+// Leaf is a block of the input.
+type Leaf struct {
+	Offset int64
+	Length int64
+	Digest Digest
+}
+
+// Range is a half open range of leaf indices.
+type Range struct {
+	Start, End int
+}
+
+// Tree is an immutable Merkle tree.
+type Tree struct {
+	leaves []Leaf
+	levels [][]Digest // levels[0] are the leaf digests, the last level is the root
+}
+
+type hasher struct {
+	hi, lo *xxhash.XXHash64
+}
+
+func newHasher(prefix byte) hasher {
+	h := hasher{xxhash.NewS64(seedHi), xxhash.NewS64(seedLo)}
+	h.Write([]byte{prefix})
+	return h
+}
+
+func (h hasher) Write(b []byte) (int, error) {
+	h.hi.Write(b)
+	return h.lo.Write(b)
+}
+
+func (h hasher) Sum() (d Digest) {
+	binary.BigEndian.PutUint64(d[:8], h.hi.Sum64())
+	binary.BigEndian.PutUint64(d[8:], h.lo.Sum64())
+	return
+}
+
+// LeafDigest returns the leaf digest of block.
+func LeafDigest(block []byte) Digest {
+	h := newHasher(leafPrefix)
+	h.Write(block)
+	return h.Sum()
+}
+
+func interiorDigest(l, r Digest) Digest {
+	h := newHasher(interiorPrefix)
+	h.Write(l[:])
+	h.Write(r[:])
+	return h.Sum()
+}
+
+// Build reads r until EOF and builds a tree of blockSize blocks, the last block may be shorter.
+func Build(r io.Reader, blockSize int64) (*Tree, error) {
+	if blockSize <= 0 {
+		return nil, errors.New("merkle: invalid block size")
+	}
+
+	var (
+		leaves []Leaf
+		off    int64
+	)
+	for {
+		h := newHasher(leafPrefix)
+		n, err := io.CopyN(h, r, blockSize)
+		if n > 0 {
+			leaves = append(leaves, Leaf{Offset: off, Length: n, Digest: h.Sum()})
+			off += n
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return FromLeaves(leaves), nil
+}
+
+// BuildCDC reads r until EOF and builds a tree of content-defined chunks.
+func BuildCDC(r io.Reader, opts cdc.Options) (*Tree, error) {
+	c, err := cdc.NewChunker(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	var leaves []Leaf
+	for c.Next() {
+		ch := c.Chunk()
+		leaves = append(leaves, Leaf{Offset: ch.Offset, Length: int64(ch.Length), Digest: LeafDigest(ch.Data)})
+	}
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	return FromLeaves(leaves), nil
+}
+
+// FromLeaves builds a tree from leaves, an empty input is represented by a single empty leaf.
+func FromLeaves(leaves []Leaf) *Tree {
+	if len(leaves) == 0 {
+		leaves = []Leaf{{Digest: LeafDigest(nil)}}
+	}
+
+	t := &Tree{leaves: append([]Leaf(nil), leaves...)}
+
+	lvl := make([]Digest, len(leaves))
+	for i, l := range leaves {
+		lvl[i] = l.Digest
+	}
+	t.levels = append(t.levels, lvl)
+
+	for len(lvl) > 1 {
+		next := make([]Digest, (len(lvl)+1)/2)
+		for i := range next {
+			if 2*i+1 < len(lvl) {
+				next[i] = interiorDigest(lvl[2*i], lvl[2*i+1])
+			} else {
+				next[i] = lvl[2*i]
+			}
+		}
+		t.levels = append(t.levels, next)
+		lvl = next
+	}
+	return t
+}
+
+// Root returns the root digest.
+func (t *Tree) Root() Digest { return t.levels[len(t.levels)-1][0] }
+
+// Height returns the number of levels, a tree with a single leaf has a height of 1.
+func (t *Tree) Height() int { return len(t.levels) }
+
+// NumLeaves returns the number of leaves.
+func (t *Tree) NumLeaves() int { return len(t.leaves) }
+
+// Leaf returns the i-th leaf.
+func (t *Tree) Leaf(i int) Leaf { return t.leaves[i] }
+
+// Level returns the digests on level l, level 0 holds the leaves.
+func (t *Tree) Level(l int) []Digest { return t.levels[l] }
+
+// Node returns the digest of node i on level l, and false if it doesn't exist.
+func (t *Tree) Node(l, i int) (Digest, bool) {
+	if l < 0 || l >= len(t.levels) || i < 0 || i >= len(t.levels[l]) {
+		return Digest{}, false
+	}
+	return t.levels[l][i], true
+}
+
+// Proof is an inclusion proof for a single leaf.
+type Proof struct {
+	Index     int
+	NumLeaves int
+	Siblings  []Digest
+}
+
+// Prove returns the inclusion proof for leaf i.
+func (t *Tree) Prove(i int) (Proof, error) {
+	if i < 0 || i >= len(t.leaves) {
+		return Proof{}, errors.New("merkle: leaf index out of range")
+	}
+	p := Proof{Index: i, NumLeaves: len(t.leaves)}
+	for _, lvl := range t.levels[:len(t.levels)-1] {
+		if s := i ^ 1; s < len(lvl) {
+			p.Siblings = append(p.Siblings, lvl[s])
+		}
+		i >>= 1
+	}
+	return p, nil
+}
+
+// Verify reports whether p proves that leaf is part of the tree with the given root.
+func (p Proof) Verify(root, leaf Digest) bool {
+	if p.Index < 0 || p.Index >= p.NumLeaves {
+		return false
+	}
+	var (
+		d        = leaf
+		i, n     = p.Index, p.NumLeaves
+		siblings = p.Siblings
+	)
+	for n > 1 {
+		if s := i ^ 1; s < n {
+			if len(siblings) == 0 {
+				return false
+			}
+			if i&1 == 0 {
+				d = interiorDigest(d, siblings[0])
+			} else {
+				d = interiorDigest(siblings[0], d)
+			}
+			siblings = siblings[1:]
+		}
+		i, n = i>>1, (n+1)/2
+	}
+	return len(siblings) == 0 && d == root
+}
+
+// Diff returns the sorted, merged ranges of leaf indices that differ between a and b.
+// Leaves that only exist in one of the trees are included.
+// Only subtrees whose digests differ are visited.
+func Diff(a, b *Tree) []Range {
+	var (
+		out []Range
+		top = a.Height()
+	)
+	if b.Height() > top {
+		top = b.Height()
+	}
+
+	var walk func(l, i int)
+	walk = func(l, i int) {
+		start := i << uint(l)
+		if start >= a.NumLeaves() && start >= b.NumLeaves() {
+			return
+		}
+		if a.sameNode(b, l, i) {
+			return
+		}
+		if l == 0 {
+			out = appendRange(out, Range{start, start + 1})
+			return
+		}
+		walk(l-1, 2*i)
+		walk(l-1, 2*i+1)
+	}
+	walk(top-1, 0)
+	return out
+}
+
+// sameNode reports whether node i on level l exists in both trees, covers the same leaves and has the same digest.
+func (t *Tree) sameNode(o *Tree, l, i int) bool {
+	a, ok1 := t.Node(l, i)
+	b, ok2 := o.Node(l, i)
+	if !ok1 || !ok2 || a != b {
+		return false
+	}
+	end := (i + 1) << uint(l)
+	return end <= t.NumLeaves() && end <= o.NumLeaves() || t.NumLeaves() == o.NumLeaves()
+}
+
+func appendRange(rs []Range, r Range) []Range {
+	if n := len(rs); n > 0 && rs[n-1].End == r.Start {
+		rs[n-1].End = r.End
+		return rs
+	}
+	return append(rs, r)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+//
+// The format is a magic header and the number of leaves, followed by the offset, length and digest
+// of every leaf, integers are little endian. Interior nodes are rebuilt by UnmarshalBinary.
+func (t *Tree) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, len(magic)+8+len(t.leaves)*leafSize)
+	b = append(b, magic...)
+	b = appendUint64(b, uint64(len(t.leaves)))
+	for _, l := range t.leaves {
+		b = appendUint64(b, uint64(l.Offset))
+		b = appendUint64(b, uint64(l.Length))
+		b = append(b, l.Digest[:]...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *Tree) UnmarshalBinary(b []byte) error {
+	if len(b) < len(magic) || !bytes.Equal(b[:len(magic)], []byte(magic)) {
+		return errors.New("merkle: invalid tree identifier")
+	}
+	b = b[len(magic):]
+	if len(b) < 8 {
+		return errors.New("merkle: invalid tree size")
+	}
+	n := binary.LittleEndian.Uint64(b)
+	b = b[8:]
+	if n == 0 || n > uint64(len(b))/leafSize || uint64(len(b)) != n*leafSize {
+		return errors.New("merkle: invalid tree size")
+	}
+
+	leaves := make([]Leaf, n)
+	for i := range leaves {
+		leaves[i].Offset = int64(binary.LittleEndian.Uint64(b))
+		leaves[i].Length = int64(binary.LittleEndian.Uint64(b[8:]))
+		copy(leaves[i].Digest[:], b[16:leafSize])
+		b = b[leafSize:]
+	}
+	*t = *FromLeaves(leaves)
+	return nil
+}
+
+func appendUint64(b []byte, x uint64) []byte {
+	var a [8]byte
+	binary.LittleEndian.PutUint64(a[:], x)
+	return append(b, a[:]...)
+}
@@ -0,0 +1,156 @@
+package merkle_test
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/OneOfOne/xxhash/cdc"
+	"github.com/OneOfOne/xxhash/merkle"
+)
+
+func randomData(n int, seed int64) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(b)
+	return b
+}
+
+func build(t testing.TB, data []byte, bs int64) *merkle.Tree {
+	tr, err := merkle.Build(bytes.NewReader(data), bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+func TestBuild(t *testing.T) {
+	data := randomData(10000, 1)
+	tr := build(t, data, 1000)
+	if tr.NumLeaves() != 10 || tr.Height() != 5 {
+		t.Fatalf("unexpected shape: %d leaves, height %d", tr.NumLeaves(), tr.Height())
+	}
+	for i := 0; i < tr.NumLeaves(); i++ {
+		l := tr.Leaf(i)
+		if l.Offset != int64(i*1000) || l.Length != 1000 || l.Digest != merkle.LeafDigest(data[i*1000:(i+1)*1000]) {
+			t.Fatalf("bad leaf %d: %+v", i, l)
+		}
+	}
+	if tr.Root() == build(t, data[:9999], 1000).Root() {
+		t.Fatal("truncated input has the same root")
+	}
+	if empty := build(t, nil, 1000); empty.NumLeaves() != 1 || empty.Root() != merkle.LeafDigest(nil) {
+		t.Fatal("unexpected tree for an empty input")
+	}
+
+	// a leaf must not be confused with an interior node over the same bytes.
+	two := merkle.FromLeaves([]merkle.Leaf{{Digest: merkle.LeafDigest([]byte("a"))}, {Digest: merkle.LeafDigest([]byte("b"))}})
+	l0, l1 := two.Level(0)[0], two.Level(0)[1]
+	if two.Root() == merkle.LeafDigest(append(l0[:], l1[:]...)) {
+		t.Fatal("interior nodes aren't domain separated")
+	}
+}
+
+func TestProofs(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 5, 8, 13, 33} {
+		tr := build(t, randomData(n*64, int64(n)), 64)
+		for i := 0; i < n; i++ {
+			p, err := tr.Prove(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			leaf := tr.Leaf(i).Digest
+			if !p.Verify(tr.Root(), leaf) {
+				t.Fatalf("n=%d: proof for leaf %d didn't verify", n, i)
+			}
+			if n > 1 {
+				if p.Verify(tr.Root(), tr.Leaf((i+1)%n).Digest) {
+					t.Fatalf("n=%d: proof for leaf %d verified the wrong leaf", n, i)
+				}
+				p.Index = (i + 1) % n
+				if p.Verify(tr.Root(), leaf) {
+					t.Fatalf("n=%d: proof for leaf %d verified at the wrong index", n, i)
+				}
+			}
+		}
+		if _, err := tr.Prove(n); err == nil {
+			t.Fatal("expected an error for an out of range leaf")
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	var (
+		a = randomData(100*64, 1)
+		b = append([]byte(nil), a...)
+	)
+	b[5*64] ^= 1
+	b[6*64] ^= 1
+	b[40*64+10] ^= 1
+	b = append(b, randomData(3*64, 2)...)
+
+	ta, tb := build(t, a, 64), build(t, b, 64)
+	want := []merkle.Range{{5, 7}, {40, 41}, {100, 103}}
+	if got := merkle.Diff(ta, tb); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if got := merkle.Diff(tb, ta); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if got := merkle.Diff(ta, ta); len(got) != 0 {
+		t.Fatalf("expected no differences, got %v", got)
+	}
+}
+
+func TestBuildCDC(t *testing.T) {
+	data := randomData(1<<20, 3)
+	edited := append(append(append([]byte(nil), data[:1000]...), "inserted"...), data[1000:]...)
+
+	opts := cdc.Options{MinSize: 1 << 10, AvgSize: 4 << 10, MaxSize: 16 << 10}
+	ta, err := merkle.BuildCDC(bytes.NewReader(data), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tb, _ := merkle.BuildCDC(bytes.NewReader(edited), opts)
+
+	// with content-defined chunks, only the chunks around the edit differ, after that the leaf indices may shift.
+	seen := make(map[merkle.Digest]bool)
+	for i := 0; i < ta.NumLeaves(); i++ {
+		seen[ta.Leaf(i).Digest] = true
+	}
+	var changed int
+	for i := 0; i < tb.NumLeaves(); i++ {
+		if !seen[tb.Leaf(i).Digest] {
+			changed++
+		}
+	}
+	if changed == 0 || changed > 2 {
+		t.Fatalf("expected 1 or 2 changed chunks, got %d", changed)
+	}
+}
+
+func TestBinaryMarshaling(t *testing.T) {
+	tr := build(t, randomData(12345, 4), 1000)
+	b, err := tr.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nt merkle.Tree
+	if err := nt.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if nt.Root() != tr.Root() || nt.NumLeaves() != tr.NumLeaves() || nt.Leaf(12) != tr.Leaf(12) {
+		t.Fatal("tree changed after a round trip")
+	}
+	if err := nt.UnmarshalBinary(b[:len(b)-1]); err == nil {
+		t.Fatal("expected an error for a truncated tree")
+	}
+}
+
+func BenchmarkBuild(b *testing.B) {
+	data := randomData(16<<20, 5)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		merkle.Build(bytes.NewReader(data), 64<<10)
+	}
+}
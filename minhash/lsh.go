@@ -0,0 +1,131 @@
+package minhash
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+// LSH groups signatures into buckets by banding, signatures that share a bucket in
+// any band are candidate pairs.
+//
+// With b bands of r rows, two sets with Jaccard similarity s become candidates with
+// probability 1 - (1 - s^r)^b.
+type LSH struct {
+	bands, rows int
+	buckets     []map[uint64][]string
+}
+
+// NewLSH returns an LSH index for signatures of length bands*rows.
+func NewLSH(bands, rows int) *LSH {
+	l := &LSH{
+		bands:   bands,
+		rows:    rows,
+		buckets: make([]map[uint64][]string, bands),
+	}
+	for i := range l.buckets {
+		l.buckets[i] = make(map[uint64][]string)
+	}
+	return l
+}
+
+// Threshold returns the approximate similarity at which the probability of becoming a candidate is 1/2.
+func (l *LSH) Threshold() float64 {
+	return math.Pow(1/float64(l.bands), 1/float64(l.rows))
+}
+
+// Add indexes sig under id, sig must have at least bands*rows values.
+func (l *LSH) Add(id string, sig []uint64) {
+	for i := range l.buckets {
+		k := l.bandKey(i, sig)
+		l.buckets[i][k] = append(l.buckets[i][k], id)
+	}
+}
+
+// Query returns the sorted ids that share at least one bucket with sig.
+func (l *LSH) Query(sig []uint64) []string {
+	seen := make(map[string]struct{})
+	for i, b := range l.buckets {
+		for _, id := range b[l.bandKey(i, sig)] {
+			seen[id] = struct{}{}
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// Buckets returns every bucket with more than one id, sorted by band then by contents.
+func (l *LSH) Buckets() [][]string {
+	var out [][]string
+	for _, b := range l.buckets {
+		var band [][]string
+		for _, ids := range b {
+			if len(ids) > 1 {
+				ids = append([]string(nil), ids...)
+				sort.Strings(ids)
+				band = append(band, ids)
+			}
+		}
+		sort.Slice(band, func(i, j int) bool { return lessStrings(band[i], band[j]) })
+		out = append(out, band...)
+	}
+	return out
+}
+
+// CandidatePairs returns the unique sorted pairs of ids that share at least one bucket.
+func (l *LSH) CandidatePairs() [][2]string {
+	seen := make(map[[2]string]struct{})
+	for _, ids := range l.Buckets() {
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				if ids[i] != ids[j] {
+					seen[[2]string{ids[i], ids[j]}] = struct{}{}
+				}
+			}
+		}
+	}
+
+	out := make([][2]string, 0, len(seen))
+	for p := range seen {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i][0] != out[j][0] {
+			return out[i][0] < out[j][0]
+		}
+		return out[i][1] < out[j][1]
+	})
+	return out
+}
+
+// bandKey hashes the rows of band i, seeded with the band index so equal rows in different bands don't collide.
+func (l *LSH) bandKey(i int, sig []uint64) uint64 {
+	var (
+		h = xxhash.NewS64(uint64(i))
+		b [8]byte
+	)
+	for _, v := range sig[i*l.rows : (i+1)*l.rows] {
+		binary.LittleEndian.PutUint64(b[:], v)
+		h.Write(b[:])
+	}
+	return h.Sum64()
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func lessStrings(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
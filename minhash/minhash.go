@@ -0,0 +1,163 @@
+// Package minhash implements MinHash signatures, b-bit MinHash and LSH banding for
+// estimating the Jaccard similarity of sets, e.g. the shingles of a document.
+//
+// Each of the k permutations is XXH64 with its own seed, so a token is hashed once per permutation,
+// string tokens are hashed with xxhash.ChecksumString64S without copying them.
+package minhash
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+// MinHash computes a signature of k minimum hashes, it is not safe for concurrent use.
+type MinHash struct {
+	seeds []uint64
+	mins  []uint64
+}
+
+// New returns a MinHash with k permutations, with seeds derived from seed.
+// Signatures are only comparable if they were created with the same k and seed.
+func New(k int, seed uint64) *MinHash {
+	mh := &MinHash{
+		seeds: make([]uint64, k),
+		mins:  make([]uint64, k),
+	}
+	var b [8]byte
+	for i := range mh.seeds {
+		binary.LittleEndian.PutUint64(b[:], uint64(i))
+		mh.seeds[i] = xxhash.Checksum64S(b[:], seed)
+	}
+	mh.Reset()
+	return mh
+}
+
+// Reset clears the signature so the MinHash can be reused for another set.
+func (mh *MinHash) Reset() {
+	for i := range mh.mins {
+		mh.mins[i] = math.MaxUint64
+	}
+}
+
+// Push adds a token to the set.
+func (mh *MinHash) Push(tok []byte) {
+	for i, s := range mh.seeds {
+		if h := xxhash.Checksum64S(tok, s); h < mh.mins[i] {
+			mh.mins[i] = h
+		}
+	}
+}
+
+// PushString adds a token to the set without copying it.
+func (mh *MinHash) PushString(tok string) {
+	for i, s := range mh.seeds {
+		if h := xxhash.ChecksumString64S(tok, s); h < mh.mins[i] {
+			mh.mins[i] = h
+		}
+	}
+}
+
+// PushStrings adds all the tokens to the set.
+func (mh *MinHash) PushStrings(toks []string) {
+	for _, tok := range toks {
+		mh.PushString(tok)
+	}
+}
+
+// Signature returns a copy of the current signature.
+func (mh *MinHash) Signature() []uint64 {
+	return append([]uint64(nil), mh.mins...)
+}
+
+// Size returns the number of permutations.
+func (mh *MinHash) Size() int { return len(mh.seeds) }
+
+// Jaccard estimates the Jaccard similarity of the sets that produced the signatures a and b.
+// It returns 0 if the signatures have different lengths.
+func Jaccard(a, b []uint64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var n int
+	for i := range a {
+		if a[i] == b[i] {
+			n++
+		}
+	}
+	return float64(n) / float64(len(a))
+}
+
+// BBitSignature is a MinHash signature that only keeps the lowest Bits bits of every value.
+type BBitSignature struct {
+	Bits uint8
+	K    int
+	Data []uint64
+}
+
+// Compress returns the b-bit version of sig, b must be between 1 and 64.
+func Compress(sig []uint64, b uint8) BBitSignature {
+	if b < 1 || b > 64 {
+		panic("minhash: b must be between 1 and 64")
+	}
+	s := BBitSignature{
+		Bits: b,
+		K:    len(sig),
+		Data: make([]uint64, (len(sig)*int(b)+63)/64),
+	}
+	mask := uint64(math.MaxUint64) >> (64 - b)
+	for i, v := range sig {
+		s.set(i, v&mask)
+	}
+	return s
+}
+
+// At returns the i-th compressed value.
+func (s BBitSignature) At(i int) uint64 {
+	var (
+		pos    = uint(i) * uint(s.Bits)
+		w, off = pos / 64, pos % 64
+		mask   = uint64(math.MaxUint64) >> (64 - s.Bits)
+		v      = s.Data[w] >> off
+	)
+	if off+uint(s.Bits) > 64 {
+		v |= s.Data[w+1] << (64 - off)
+	}
+	return v & mask
+}
+
+func (s BBitSignature) set(i int, v uint64) {
+	pos := uint(i) * uint(s.Bits)
+	w, off := pos/64, pos%64
+	s.Data[w] |= v << off
+	if off+uint(s.Bits) > 64 {
+		s.Data[w+1] |= v >> (64 - off)
+	}
+}
+
+// JaccardBBit estimates the Jaccard similarity from two b-bit signatures.
+//
+// Two random b-bit values match with probability 2^-b, the estimate corrects for that:
+// J = (matches/K - 2^-b) / (1 - 2^-b).
+// It returns 0 if the signatures were compressed with different parameters.
+func JaccardBBit(a, b BBitSignature) float64 {
+	if a.Bits != b.Bits || a.K != b.K || a.K == 0 {
+		return 0
+	}
+	var n int
+	for i := 0; i < a.K; i++ {
+		if a.At(i) == b.At(i) {
+			n++
+		}
+	}
+	var (
+		p = float64(n) / float64(a.K)
+		c = math.Ldexp(1, -int(a.Bits))
+		j = (p - c) / (1 - c)
+	)
+	if j < 0 {
+		return 0
+	}
+	return j
+}
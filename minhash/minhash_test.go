@@ -0,0 +1,110 @@
+package minhash_test
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/OneOfOne/xxhash/minhash"
+)
+
+// makeSets returns two sets of n tokens sharing shared tokens, their Jaccard similarity is shared / (2n - shared).
+func makeSets(n, shared int) (a, b []string) {
+	for i := 0; i < n; i++ {
+		a = append(a, "tok-"+strconv.Itoa(i))
+		if i < shared {
+			b = append(b, "tok-"+strconv.Itoa(i))
+		} else {
+			b = append(b, "other-"+strconv.Itoa(i))
+		}
+	}
+	return
+}
+
+func signature(k int, toks []string) []uint64 {
+	mh := minhash.New(k, 1)
+	mh.PushStrings(toks)
+	return mh.Signature()
+}
+
+func TestJaccard(t *testing.T) {
+	for _, shared := range []int{0, 250, 500, 800, 1000} {
+		a, b := makeSets(1000, shared)
+		want := float64(shared) / float64(2000-shared)
+		got := minhash.Jaccard(signature(512, a), signature(512, b))
+		if math.Abs(got-want) > 0.07 {
+			t.Errorf("shared=%d: expected ~%.3f, got %.3f", shared, want, got)
+		}
+	}
+}
+
+func TestBBit(t *testing.T) {
+	for _, shared := range []int{0, 500, 800, 1000} {
+		a, b := makeSets(1000, shared)
+		want := float64(shared) / float64(2000-shared)
+		sa, sb := signature(1024, a), signature(1024, b)
+		for _, bits := range []uint8{1, 2, 4, 7, 8} {
+			got := minhash.JaccardBBit(minhash.Compress(sa, bits), minhash.Compress(sb, bits))
+			if math.Abs(got-want) > 0.1 {
+				t.Errorf("shared=%d, b=%d: expected ~%.3f, got %.3f", shared, bits, want, got)
+			}
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	sig := signature(100, []string{"a", "b", "c"})
+	for b := uint8(1); b <= 64; b++ {
+		s := minhash.Compress(sig, b)
+		mask := uint64(math.MaxUint64) >> (64 - b)
+		for i, v := range sig {
+			if got := s.At(i); got != v&mask {
+				t.Fatalf("b=%d, i=%d: expected 0x%x, got 0x%x", b, i, v&mask, got)
+			}
+		}
+	}
+}
+
+func TestPushString(t *testing.T) {
+	a, b := minhash.New(64, 7), minhash.New(64, 7)
+	for _, tok := range strings.Fields("the quick brown fox jumps over the lazy dog") {
+		a.Push([]byte(tok))
+		b.PushString(tok)
+	}
+	if minhash.Jaccard(a.Signature(), b.Signature()) != 1 {
+		t.Fatal("Push and PushString disagree")
+	}
+}
+
+func TestLSH(t *testing.T) {
+	l := minhash.NewLSH(32, 4)
+	base, near := makeSets(500, 480)
+	_, far := makeSets(500, 10)
+
+	l.Add("base", signature(128, base))
+	l.Add("near", signature(128, near))
+	l.Add("far", signature(128, far))
+
+	pairs := l.CandidatePairs()
+	if len(pairs) != 1 || pairs[0] != [2]string{"base", "near"} {
+		t.Fatalf("unexpected candidate pairs: %v", pairs)
+	}
+	if got := l.Query(signature(128, base)); len(got) != 2 || got[0] != "base" || got[1] != "near" {
+		t.Fatalf("unexpected query result: %v", got)
+	}
+	if th := l.Threshold(); th < 0.3 || th > 0.5 {
+		t.Fatalf("unexpected threshold %f", th)
+	}
+}
+
+func BenchmarkPushString(b *testing.B) {
+	for _, k := range []int{64, 128, 256} {
+		b.Run(strconv.Itoa(k), func(b *testing.B) {
+			mh := minhash.New(k, 0)
+			for i := 0; i < b.N; i++ {
+				mh.PushString("some shingle of text")
+			}
+		})
+	}
+}
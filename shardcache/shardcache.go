@@ -0,0 +1,287 @@
+// Package shardcache implements a concurrent string keyed cache split into shards,
+// the shard of a key is picked from its XXH64 checksum.
+//
+// Every shard has its own lock, map and LRU list, so goroutines working on different
+// keys rarely contend. Entries can expire after a TTL and each shard can be bounded
+// to a number of entries, evicting the least recently used ones.
+package shardcache
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+// DefaultShards is the number of shards used when Options.Shards is 0.
+const DefaultShards = 64
+
+// Options configures a Cache, zero values select the defaults.
+type Options struct {
+	// Shards is the number of shards, it is rounded up to a power of two.
+	Shards int
+
+	// Capacity bounds the total number of entries, it is split evenly between the shards.
+	// When a shard is full, its least recently used entry is evicted.
+	// 0 means unbounded.
+	Capacity int
+
+	// TTL is the time to live of entries added with Set, 0 means they don't expire.
+	TTL time.Duration
+
+	// Seed is passed to xxhash.ChecksumString64S to pick shards.
+	Seed uint64
+
+	// RandomSeed replaces Seed with a random value from crypto/rand.
+	RandomSeed bool
+
+	// Now is used to read the current time, mostly useful for tests.
+	// Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Stats holds cache counters.
+type Stats struct {
+	Hits, Misses uint64
+	Evictions    uint64 // entries removed to respect Capacity
+	Expirations  uint64 // entries removed because their TTL passed
+}
+
+type entry[V any] struct {
+	key        string
+	val        V
+	expires    int64 // unix nanoseconds, 0 means never
+	prev, next *entry[V]
+}
+
+type shard[V any] struct {
+	mu    sync.Mutex
+	items map[string]*entry[V]
+	root  entry[V] // sentinel of the LRU list, root.next is the most recently used, only ordered if cap > 0
+	cap   int
+
+	hits, misses, evictions, expirations uint64
+
+	_ [64]byte // keep shards on separate cache lines
+}
+
+// Cache is a sharded cache from strings to V, it is safe for concurrent use.
+type Cache[V any] struct {
+	shards []shard[V]
+	mask   uint64
+	seed   uint64
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// New returns a new Cache.
+func New[V any](opts Options) *Cache[V] {
+	n := 1
+	for n < opts.Shards || (opts.Shards == 0 && n < DefaultShards) {
+		n <<= 1
+	}
+
+	c := &Cache[V]{
+		shards: make([]shard[V], n),
+		mask:   uint64(n - 1),
+		seed:   opts.Seed,
+		ttl:    opts.TTL,
+		now:    opts.Now,
+	}
+
+	if opts.RandomSeed {
+		var b [8]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			panic("shardcache: can't read a random seed: " + err.Error())
+		}
+		c.seed = binary.LittleEndian.Uint64(b[:])
+	}
+	if c.now == nil {
+		c.now = time.Now
+	}
+
+	perShard := 0
+	if opts.Capacity > 0 {
+		perShard = (opts.Capacity + n - 1) / n
+	}
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.items = make(map[string]*entry[V])
+		s.root.next, s.root.prev = &s.root, &s.root
+		s.cap = perShard
+	}
+	return c
+}
+
+// ShardIndex returns the index of the shard that holds key.
+func (c *Cache[V]) ShardIndex(key string) int {
+	return int(xxhash.ChecksumString64S(key, c.seed) & c.mask)
+}
+
+func (c *Cache[V]) shard(key string) *shard[V] {
+	return &c.shards[c.ShardIndex(key)]
+}
+
+// Get returns the value stored under key and whether it was found and not expired.
+func (c *Cache[V]) Get(key string) (v V, ok bool) {
+	s := c.shard(key)
+	s.mu.Lock()
+	e := s.items[key]
+	if e != nil && e.expires != 0 && e.expired(c.nowNano()) {
+		s.remove(e)
+		s.expirations++
+		e = nil
+	}
+	if e == nil {
+		s.misses++
+		s.mu.Unlock()
+		return
+	}
+	s.hits++
+	if s.cap > 0 {
+		s.moveToFront(e)
+	}
+	v = e.val
+	s.mu.Unlock()
+	return v, true
+}
+
+// Set stores v under key with the default TTL.
+func (c *Cache[V]) Set(key string, v V) {
+	c.SetWithTTL(key, v, c.ttl)
+}
+
+// SetWithTTL stores v under key, the entry expires after ttl unless ttl is 0.
+func (c *Cache[V]) SetWithTTL(key string, v V, ttl time.Duration) {
+	var expires int64
+	if ttl > 0 {
+		expires = c.nowNano() + int64(ttl)
+	}
+
+	s := c.shard(key)
+	s.mu.Lock()
+	if e := s.items[key]; e != nil {
+		e.val, e.expires = v, expires
+		if s.cap > 0 {
+			s.moveToFront(e)
+		}
+		s.mu.Unlock()
+		return
+	}
+
+	if s.cap > 0 && len(s.items) >= s.cap {
+		s.remove(s.root.prev)
+		s.evictions++
+	}
+	e := &entry[V]{key: key, val: v, expires: expires}
+	s.items[key] = e
+	s.pushFront(e)
+	s.mu.Unlock()
+}
+
+// Delete removes key and reports whether it was present.
+func (c *Cache[V]) Delete(key string) bool {
+	s := c.shard(key)
+	s.mu.Lock()
+	e := s.items[key]
+	if e != nil {
+		s.remove(e)
+	}
+	s.mu.Unlock()
+	return e != nil
+}
+
+// Range calls fn for every entry that hasn't expired until it returns false.
+// Each shard is locked while it is visited, so fn must not call other methods of the cache.
+func (c *Cache[V]) Range(fn func(key string, v V) bool) {
+	now := c.nowNano()
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		for e := s.root.next; e != &s.root; e = e.next {
+			if e.expired(now) {
+				continue
+			}
+			if !fn(e.key, e.val) {
+				s.mu.Unlock()
+				return
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// DeleteExpired removes all the expired entries and returns how many were removed.
+// Expired entries are otherwise only removed when they are accessed.
+func (c *Cache[V]) DeleteExpired() (n int) {
+	now := c.nowNano()
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		for e := s.root.next; e != &s.root; {
+			next := e.next
+			if e.expired(now) {
+				s.remove(e)
+				s.expirations++
+				n++
+			}
+			e = next
+		}
+		s.mu.Unlock()
+	}
+	return
+}
+
+// Len returns the number of entries, including expired ones that haven't been removed yet.
+func (c *Cache[V]) Len() (n int) {
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		n += len(s.items)
+		s.mu.Unlock()
+	}
+	return
+}
+
+// Stats returns the sum of the counters of all the shards.
+func (c *Cache[V]) Stats() (st Stats) {
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		st.Hits += s.hits
+		st.Misses += s.misses
+		st.Evictions += s.evictions
+		st.Expirations += s.expirations
+		s.mu.Unlock()
+	}
+	return
+}
+
+// NumShards returns the number of shards.
+func (c *Cache[V]) NumShards() int { return len(c.shards) }
+
+func (c *Cache[V]) nowNano() int64 { return c.now().UnixNano() }
+
+func (e *entry[V]) expired(now int64) bool { return e.expires != 0 && now >= e.expires }
+
+func (s *shard[V]) pushFront(e *entry[V]) {
+	e.prev, e.next = &s.root, s.root.next
+	s.root.next.prev = e
+	s.root.next = e
+}
+
+func (s *shard[V]) moveToFront(e *entry[V]) {
+	if s.root.next == e {
+		return
+	}
+	e.prev.next, e.next.prev = e.next, e.prev
+	s.pushFront(e)
+}
+
+func (s *shard[V]) remove(e *entry[V]) {
+	e.prev.next, e.next.prev = e.next, e.prev
+	e.prev, e.next = nil, nil
+	delete(s.items, e.key)
+}
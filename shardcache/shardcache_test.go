@@ -0,0 +1,222 @@
+package shardcache_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OneOfOne/xxhash"
+	"github.com/OneOfOne/xxhash/shardcache"
+)
+
+type clock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	c.t = c.t.Add(d)
+	c.mu.Unlock()
+}
+
+func TestGetSetDelete(t *testing.T) {
+	c := shardcache.New[int](shardcache.Options{Shards: 10})
+	if c.NumShards() != 16 {
+		t.Fatalf("expected 16 shards, got %d", c.NumShards())
+	}
+	for i := 0; i < 1000; i++ {
+		c.Set(strconv.Itoa(i), i)
+	}
+	for i := 0; i < 1000; i++ {
+		if v, ok := c.Get(strconv.Itoa(i)); !ok || v != i {
+			t.Fatalf("Get(%d) = %d, %v", i, v, ok)
+		}
+	}
+	if !c.Delete("5") || c.Delete("5") {
+		t.Fatal("unexpected Delete result")
+	}
+	if _, ok := c.Get("5"); ok {
+		t.Fatal("found a deleted key")
+	}
+
+	var n int
+	c.Range(func(k string, v int) bool {
+		if k != strconv.Itoa(v) {
+			t.Fatalf("Range: %q = %d", k, v)
+		}
+		n++
+		return true
+	})
+	if n != 999 || c.Len() != 999 {
+		t.Fatalf("expected 999 entries, got %d / %d", n, c.Len())
+	}
+
+	if st := c.Stats(); st.Hits != 1000 || st.Misses != 1 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+}
+
+func TestShardIndex(t *testing.T) {
+	c := shardcache.New[int](shardcache.Options{Shards: 32})
+	for _, k := range []string{"a", "b", "some longer key"} {
+		if got, want := c.ShardIndex(k), int(xxhash.ChecksumString64(k)&31); got != want {
+			t.Fatalf("ShardIndex(%q) = %d; want %d", k, got, want)
+		}
+	}
+
+	// with a random seed the shards are still stable for the same instance.
+	r := shardcache.New[int](shardcache.Options{RandomSeed: true})
+	if r.ShardIndex("a") != r.ShardIndex("a") {
+		t.Fatal("unstable shard index")
+	}
+}
+
+func TestLRU(t *testing.T) {
+	// a single shard makes the eviction order predictable.
+	c := shardcache.New[int](shardcache.Options{Shards: 1, Capacity: 3})
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	c.Get("a")
+	c.Set("d", 4) // evicts b
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("b should have been evicted")
+	}
+	for _, k := range []string{"a", "c", "d"} {
+		if _, ok := c.Get(k); !ok {
+			t.Fatalf("%s should still be cached", k)
+		}
+	}
+	if st := c.Stats(); st.Evictions != 1 {
+		t.Fatalf("expected 1 eviction, got %+v", st)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	clk := &clock{t: time.Unix(1000, 0)}
+	c := shardcache.New[string](shardcache.Options{TTL: time.Minute, Now: clk.Now})
+	c.Set("a", "a")
+	c.SetWithTTL("b", "b", time.Hour)
+	c.SetWithTTL("c", "c", 0)
+
+	clk.Add(2 * time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("a should have expired")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Fatal("b shouldn't have expired")
+	}
+
+	clk.Add(2 * time.Hour)
+	if n := c.DeleteExpired(); n != 1 {
+		t.Fatalf("expected 1 expired entry, got %d", n)
+	}
+	if _, ok := c.Get("c"); !ok || c.Len() != 1 {
+		t.Fatal("c shouldn't expire")
+	}
+	if st := c.Stats(); st.Expirations != 2 {
+		t.Fatalf("expected 2 expirations, got %+v", st)
+	}
+}
+
+// TestConcurrent is meant to be run with -race.
+func TestConcurrent(t *testing.T) {
+	c := shardcache.New[int](shardcache.Options{Capacity: 500, TTL: time.Hour})
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				k := strconv.Itoa((g * i) % 1000)
+				switch i % 4 {
+				case 0:
+					c.Set(k, i)
+				case 1, 2:
+					c.Get(k)
+				case 3:
+					c.Delete(k)
+				}
+				if i%500 == 0 {
+					c.Range(func(string, int) bool { return true })
+					c.DeleteExpired()
+					c.Stats()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := c.Len(); n > 500+c.NumShards() {
+		t.Fatalf("cache grew past its capacity: %d", n)
+	}
+}
+
+var benchKeys = func() []string {
+	keys := make([]string, 1<<16)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+	return keys
+}()
+
+// lockedMap is the single lock baseline.
+type lockedMap struct {
+	mu sync.RWMutex
+	m  map[string]int
+}
+
+func BenchmarkContention(b *testing.B) {
+	for _, shards := range []int{1, 16, 64, 256} {
+		b.Run("Shards-"+strconv.Itoa(shards), func(b *testing.B) {
+			c := shardcache.New[int](shardcache.Options{Shards: shards})
+			for i, k := range benchKeys {
+				c.Set(k, i)
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				var i int
+				for pb.Next() {
+					k := benchKeys[i&(len(benchKeys)-1)]
+					if i%8 == 0 {
+						c.Set(k, i)
+					} else {
+						c.Get(k)
+					}
+					i++
+				}
+			})
+		})
+	}
+	b.Run("RWMutexMap", func(b *testing.B) {
+		m := &lockedMap{m: make(map[string]int)}
+		for i, k := range benchKeys {
+			m.m[k] = i
+		}
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			var i int
+			for pb.Next() {
+				k := benchKeys[i&(len(benchKeys)-1)]
+				if i%8 == 0 {
+					m.mu.Lock()
+					m.m[k] = i
+					m.mu.Unlock()
+				} else {
+					m.mu.RLock()
+					_ = m.m[k]
+					m.mu.RUnlock()
+				}
+				i++
+			}
+		})
+	})
+}
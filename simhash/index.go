@@ -0,0 +1,100 @@
+package simhash
+
+import "sort"
+
+// Match is a fingerprint returned by Index.Query.
+type Match struct {
+	ID          string
+	Fingerprint uint64
+	Distance    int
+}
+
+type entry struct {
+	id string
+	fp uint64
+}
+
+type block struct {
+	shift uint
+	mask  uint64
+}
+
+// Index finds fingerprints within k bits of a query.
+//
+// The 64 bits are split into k+1 blocks, one table per block. Two fingerprints that differ
+// by at most k bits must agree on at least one whole block, so only the fingerprints
+// sharing a block with the query are compared.
+type Index struct {
+	k      int
+	blocks []block
+	tables []map[uint64][]entry
+	n      int
+}
+
+// NewIndex returns an Index for queries within k bits, k must be between 0 and 63.
+func NewIndex(k int) *Index {
+	if k < 0 || k > 63 {
+		panic("simhash: k must be between 0 and 63")
+	}
+
+	idx := &Index{
+		k:      k,
+		blocks: make([]block, k+1),
+		tables: make([]map[uint64][]entry, k+1),
+	}
+
+	var shift uint
+	for i := range idx.blocks {
+		// spread the remainder over the first blocks.
+		w := uint(64 / (k + 1))
+		if i < 64%(k+1) {
+			w++
+		}
+		idx.blocks[i] = block{shift: shift, mask: 1<<w - 1}
+		idx.tables[i] = make(map[uint64][]entry)
+		shift += w
+	}
+	return idx
+}
+
+// K returns the maximum distance the index was built for.
+func (idx *Index) K() int { return idx.k }
+
+// Len returns the number of fingerprints in the index.
+func (idx *Index) Len() int { return idx.n }
+
+// Add adds fp under id.
+func (idx *Index) Add(id string, fp uint64) {
+	e := entry{id, fp}
+	for i, b := range idx.blocks {
+		k := fp >> b.shift & b.mask
+		idx.tables[i][k] = append(idx.tables[i][k], e)
+	}
+	idx.n++
+}
+
+// Query returns every fingerprint within K() bits of fp, sorted by distance then id.
+func (idx *Index) Query(fp uint64) []Match {
+	var (
+		out  []Match
+		seen = make(map[entry]struct{})
+	)
+	for i, b := range idx.blocks {
+		for _, e := range idx.tables[i][fp>>b.shift&b.mask] {
+			if _, ok := seen[e]; ok {
+				continue
+			}
+			seen[e] = struct{}{}
+			if d := Distance(fp, e.fp); d <= idx.k {
+				out = append(out, Match{ID: e.id, Fingerprint: e.fp, Distance: d})
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Distance != out[j].Distance {
+			return out[i].Distance < out[j].Distance
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
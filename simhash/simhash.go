@@ -0,0 +1,100 @@
+// Package simhash implements SimHash fingerprints for near-duplicate detection.
+//
+// Every feature is hashed with xxhash.Checksum64S, the fingerprint has bit i set when the
+// total weight of the features with bit i set outweighs the ones with it cleared.
+// Similar inputs produce fingerprints with a small Hamming distance.
+package simhash
+
+import (
+	"math/bits"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+// Feature is a weighted input feature, e.g. a token and its frequency.
+type Feature struct {
+	Data   []byte
+	Weight int
+}
+
+// SimHash accumulates weighted features, it is not safe for concurrent use.
+type SimHash struct {
+	seed uint64
+	v    [64]int64
+}
+
+// New returns a SimHash with the seed set to 0.
+func New() *SimHash { return NewS(0) }
+
+// NewS returns a SimHash that hashes features with the specific seed.
+func NewS(seed uint64) *SimHash {
+	return &SimHash{seed: seed}
+}
+
+// Reset clears the accumulated features.
+func (s *SimHash) Reset() {
+	s.v = [64]int64{}
+}
+
+// Add adds a feature with the given weight.
+func (s *SimHash) Add(feature []byte, weight int) {
+	s.addHash(xxhash.Checksum64S(feature, s.seed), int64(weight))
+}
+
+// AddString adds a feature with the given weight without copying it.
+func (s *SimHash) AddString(feature string, weight int) {
+	s.addHash(xxhash.ChecksumString64S(feature, s.seed), int64(weight))
+}
+
+func (s *SimHash) addHash(h uint64, w int64) {
+	for i := range s.v {
+		if h&(1<<uint(i)) != 0 {
+			s.v[i] += w
+		} else {
+			s.v[i] -= w
+		}
+	}
+}
+
+// Sum64 returns the fingerprint of the features added so far.
+func (s *SimHash) Sum64() (fp uint64) {
+	for i, w := range s.v {
+		if w > 0 {
+			fp |= 1 << uint(i)
+		}
+	}
+	return
+}
+
+// Fingerprint returns the fingerprint of features with the seed set to 0.
+func Fingerprint(features []Feature) uint64 {
+	return FingerprintS(features, 0)
+}
+
+// FingerprintS returns the fingerprint of features with the specific seed.
+func FingerprintS(features []Feature, seed uint64) uint64 {
+	s := NewS(seed)
+	for _, f := range features {
+		s.Add(f.Data, f.Weight)
+	}
+	return s.Sum64()
+}
+
+// FingerprintStrings returns the fingerprint of tokens, each with a weight of 1, with the seed set to 0.
+func FingerprintStrings(tokens []string) uint64 {
+	s := New()
+	for _, t := range tokens {
+		s.AddString(t, 1)
+	}
+	return s.Sum64()
+}
+
+// Distance returns the Hamming distance between a and b.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Within reports whether a and b differ by at most k bits.
+func Within(a, b uint64, k int) bool {
+	return Distance(a, b) <= k
+}
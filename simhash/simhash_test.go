@@ -0,0 +1,113 @@
+package simhash_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/OneOfOne/xxhash/simhash"
+)
+
+const text = `Lorem ipsum dolor sit amet, consectetur adipisicing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua.
+Ut enim ad minim veniam, quis nostrud exercitation ullamco laboris nisi ut aliquip ex ea commodo consequat.
+Duis aute irure dolor in reprehenderit in voluptate velit esse cillum dolore eu fugiat nulla pariatur.`
+
+func TestNearDuplicates(t *testing.T) {
+	var (
+		orig    = strings.Fields(text)
+		edited  = append(append([]string(nil), orig[:len(orig)-2]...), "changed", "words")
+		other   = strings.Fields("The quick brown fox jumps over the lazy dog while the cat watches from the window sill")
+		fpOrig  = simhash.FingerprintStrings(orig)
+		fpEdit  = simhash.FingerprintStrings(edited)
+		fpOther = simhash.FingerprintStrings(other)
+	)
+
+	if d := simhash.Distance(fpOrig, fpEdit); d > 10 {
+		t.Errorf("near duplicate is too far: %d", d)
+	}
+	if d := simhash.Distance(fpOrig, fpOther); d < 15 {
+		t.Errorf("unrelated text is too close: %d", d)
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	fs := []simhash.Feature{{[]byte("a"), 3}, {[]byte("b"), 1}, {[]byte("c"), 2}}
+	s := simhash.NewS(5)
+	s.AddString("a", 3)
+	s.AddString("b", 1)
+	s.AddString("c", 2)
+	if a, b := simhash.FingerprintS(fs, 5), s.Sum64(); a != b {
+		t.Fatalf("0x%x != 0x%x", a, b)
+	}
+	s.Reset()
+	if s.Sum64() != 0 {
+		t.Fatal("Reset didn't clear the fingerprint")
+	}
+}
+
+func TestIndex(t *testing.T) {
+	const k = 3
+	var (
+		rnd = rand.New(rand.NewSource(1))
+		idx = simhash.NewIndex(k)
+		fps = make([]uint64, 1000)
+	)
+	for i := range fps {
+		fps[i] = rnd.Uint64()
+		idx.Add(string(rune('a'+i%26))+string(rune('0'+i/26)), fps[i])
+	}
+	if idx.Len() != len(fps) {
+		t.Fatalf("expected %d fingerprints, got %d", len(fps), idx.Len())
+	}
+
+	for i := 0; i < 200; i++ {
+		want := fps[rnd.Intn(len(fps))]
+		q := want
+		for j := 0; j < rnd.Intn(k+1); j++ {
+			q ^= 1 << uint(rnd.Intn(64))
+		}
+
+		var found bool
+		for _, m := range idx.Query(q) {
+			if m.Distance > k || m.Distance != simhash.Distance(q, m.Fingerprint) {
+				t.Fatalf("bad match %+v for 0x%x", m, q)
+			}
+			if m.Fingerprint == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("0x%x wasn't found for query 0x%x", want, q)
+		}
+
+		// compare against a linear scan.
+		var n int
+		for _, fp := range fps {
+			if simhash.Within(q, fp, k) {
+				n++
+			}
+		}
+		if got := len(idx.Query(q)); got != n {
+			t.Fatalf("expected %d matches, got %d", n, got)
+		}
+	}
+}
+
+func BenchmarkFingerprintStrings(b *testing.B) {
+	toks := strings.Fields(text)
+	for i := 0; i < b.N; i++ {
+		simhash.FingerprintStrings(toks)
+	}
+}
+
+func BenchmarkIndexQuery(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	idx := simhash.NewIndex(3)
+	for i := 0; i < 100000; i++ {
+		idx.Add("", rnd.Uint64())
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.Query(rnd.Uint64())
+	}
+}
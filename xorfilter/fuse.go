@@ -0,0 +1,213 @@
+package xorfilter
+
+import (
+	"math"
+	"math/bits"
+)
+
+const (
+	magicFuse8  = "xxf\x03"
+	magicFuse16 = "xxf\x04"
+
+	maxSegmentLength = 1 << 18
+)
+
+// fuseLayout holds the parameters of a 3-wise binary fuse filter.
+type fuseLayout struct {
+	segmentLength      uint32
+	segmentCount       uint32
+	segmentCountLength uint32
+}
+
+func newFuseLayout(n int) fuseLayout {
+	var l fuseLayout
+
+	l.segmentLength = 4
+	if n > 0 {
+		l.segmentLength = 1 << uint(math.Floor(math.Log(float64(n))/math.Log(3.33)+2.25))
+	}
+	if l.segmentLength > maxSegmentLength {
+		l.segmentLength = maxSegmentLength
+	}
+
+	var capacity uint32
+	if n > 1 {
+		sizeFactor := math.Max(1.125, 0.875+0.25*math.Log(1000000)/math.Log(float64(n)))
+		capacity = uint32(math.Round(float64(n) * sizeFactor))
+	}
+
+	segs := int64((capacity+l.segmentLength-1)/l.segmentLength) - 2
+	if segs < 1 {
+		segs = 1
+	}
+	l.segmentCount = uint32(segs)
+	l.segmentCountLength = l.segmentCount * l.segmentLength
+	return l
+}
+
+func (l fuseLayout) arrayLength() uint32 { return (l.segmentCount + 2) * l.segmentLength }
+
+// positions maps a hash to one slot in each of three consecutive segments.
+func (l fuseLayout) positions(h uint64) (uint32, uint32, uint32) {
+	hi, _ := bits.Mul64(h, uint64(l.segmentCountLength))
+	h0 := uint32(hi)
+	h1 := h0 + l.segmentLength
+	h2 := h1 + l.segmentLength
+	h1 ^= uint32(h>>18) & (l.segmentLength - 1)
+	h2 ^= uint32(h) & (l.segmentLength - 1)
+	return h0, h1, h2
+}
+
+func (l fuseLayout) params() []uint32 { return []uint32{l.segmentLength, l.segmentCount} }
+
+func fuseLayoutFromParams(p [2]uint32) (fuseLayout, bool) {
+	sl, sc := p[0], p[1]
+	if sl == 0 || sl&(sl-1) != 0 || sl > maxSegmentLength || sc == 0 || uint64(sc)*uint64(sl) > math.MaxUint32/2 {
+		return fuseLayout{}, false
+	}
+	return fuseLayout{segmentLength: sl, segmentCount: sc, segmentCountLength: sc * sl}, true
+}
+
+// BinaryFuse8 is a binary fuse filter with 8bit fingerprints, it uses about 9 to 9.5 bits per key depending on the set size
+// and has a false positive rate of about 0.39%.
+type BinaryFuse8 struct {
+	// Seed is the starting seed for Populate, after a successful Populate it holds the seed that was used.
+	Seed uint64
+
+	layout       fuseLayout
+	fingerprints []uint8
+}
+
+// Populate builds the filter from keys, which should already be well distributed, e.g. hashes.
+func (f *BinaryFuse8) Populate(keys []uint64) error {
+	keys = uniqueKeys(keys)
+	l := newFuseLayout(len(keys))
+
+	stack, seed, err := build(keys, f.Seed, l.arrayLength(), l.positions)
+	if err != nil {
+		return err
+	}
+
+	fps := make([]uint8, l.arrayLength())
+	assign8(stack, fps, l.positions)
+
+	f.Seed, f.layout, f.fingerprints = seed, l, fps
+	return nil
+}
+
+// PopulateStrings is a convenience wrapper that populates the filter with StringKey(s) for each key.
+func (f *BinaryFuse8) PopulateStrings(keys []string) error {
+	return f.Populate(stringKeys(keys))
+}
+
+// Contains reports whether key may be in the filter.
+func (f *BinaryFuse8) Contains(key uint64) bool {
+	if len(f.fingerprints) == 0 {
+		return false
+	}
+	h := mixKey(key, f.Seed)
+	h0, h1, h2 := f.layout.positions(h)
+	return uint8(fingerprint(h)) == f.fingerprints[h0]^f.fingerprints[h1]^f.fingerprints[h2]
+}
+
+// ContainsString reports whether s may be in the filter.
+func (f *BinaryFuse8) ContainsString(s string) bool {
+	return f.Contains(StringKey(s))
+}
+
+// SizeInBytes returns the size of the fingerprint table.
+func (f *BinaryFuse8) SizeInBytes() int { return len(f.fingerprints) }
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (f *BinaryFuse8) MarshalBinary() ([]byte, error) {
+	return marshal(magicFuse8, f.Seed, f.layout.params(), f.fingerprints, nil), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (f *BinaryFuse8) UnmarshalBinary(b []byte) error {
+	var params [2]uint32
+	b, seed, err := unmarshalHeader(b, magicFuse8, params[:])
+	if err != nil {
+		return err
+	}
+	l, ok := fuseLayoutFromParams(params)
+	if !ok || uint64(len(b)) != uint64(l.arrayLength()) {
+		return errInvalidSize
+	}
+	f.Seed, f.layout, f.fingerprints = seed, l, append([]uint8(nil), b...)
+	return nil
+}
+
+// BinaryFuse16 is a binary fuse filter with 16bit fingerprints, it uses about 18 to 19 bits per key depending on the set size
+// and has a false positive rate of about 0.0015%.
+type BinaryFuse16 struct {
+	// Seed is the starting seed for Populate, after a successful Populate it holds the seed that was used.
+	Seed uint64
+
+	layout       fuseLayout
+	fingerprints []uint16
+}
+
+// Populate builds the filter from keys, which should already be well distributed, e.g. hashes.
+func (f *BinaryFuse16) Populate(keys []uint64) error {
+	keys = uniqueKeys(keys)
+	l := newFuseLayout(len(keys))
+
+	stack, seed, err := build(keys, f.Seed, l.arrayLength(), l.positions)
+	if err != nil {
+		return err
+	}
+
+	fps := make([]uint16, l.arrayLength())
+	assign16(stack, fps, l.positions)
+
+	f.Seed, f.layout, f.fingerprints = seed, l, fps
+	return nil
+}
+
+// PopulateStrings is a convenience wrapper that populates the filter with StringKey(s) for each key.
+func (f *BinaryFuse16) PopulateStrings(keys []string) error {
+	return f.Populate(stringKeys(keys))
+}
+
+// Contains reports whether key may be in the filter.
+func (f *BinaryFuse16) Contains(key uint64) bool {
+	if len(f.fingerprints) == 0 {
+		return false
+	}
+	h := mixKey(key, f.Seed)
+	h0, h1, h2 := f.layout.positions(h)
+	return uint16(fingerprint(h)) == f.fingerprints[h0]^f.fingerprints[h1]^f.fingerprints[h2]
+}
+
+// ContainsString reports whether s may be in the filter.
+func (f *BinaryFuse16) ContainsString(s string) bool {
+	return f.Contains(StringKey(s))
+}
+
+// SizeInBytes returns the size of the fingerprint table.
+func (f *BinaryFuse16) SizeInBytes() int { return 2 * len(f.fingerprints) }
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (f *BinaryFuse16) MarshalBinary() ([]byte, error) {
+	return marshal(magicFuse16, f.Seed, f.layout.params(), nil, f.fingerprints), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (f *BinaryFuse16) UnmarshalBinary(b []byte) error {
+	var params [2]uint32
+	b, seed, err := unmarshalHeader(b, magicFuse16, params[:])
+	if err != nil {
+		return err
+	}
+	l, ok := fuseLayoutFromParams(params)
+	if !ok {
+		return errInvalidSize
+	}
+	fps, err := unmarshal16(b, uint64(l.arrayLength()))
+	if err != nil {
+		return err
+	}
+	f.Seed, f.layout, f.fingerprints = seed, l, fps
+	return nil
+}
@@ -0,0 +1,171 @@
+package xorfilter
+
+import "math/bits"
+
+const (
+	magicXor8  = "xxf\x01"
+	magicXor16 = "xxf\x02"
+)
+
+// Xor8 is an xor filter with 8bit fingerprints, it uses about 9.84 bits per key
+// and has a false positive rate of about 0.39%.
+type Xor8 struct {
+	// Seed is the starting seed for Populate, after a successful Populate it holds the seed that was used.
+	Seed uint64
+
+	blockLength  uint32
+	fingerprints []uint8
+}
+
+// Populate builds the filter from keys, which should already be well distributed, e.g. hashes.
+func (f *Xor8) Populate(keys []uint64) error {
+	keys = uniqueKeys(keys)
+	bl := xorBlockLength(len(keys))
+	pos := xorPositions(bl)
+
+	stack, seed, err := build(keys, f.Seed, 3*bl, pos)
+	if err != nil {
+		return err
+	}
+
+	fps := make([]uint8, 3*bl)
+	assign8(stack, fps, pos)
+
+	f.Seed, f.blockLength, f.fingerprints = seed, bl, fps
+	return nil
+}
+
+// PopulateStrings is a convenience wrapper that populates the filter with StringKey(s) for each key.
+func (f *Xor8) PopulateStrings(keys []string) error {
+	return f.Populate(stringKeys(keys))
+}
+
+// Contains reports whether key may be in the filter.
+func (f *Xor8) Contains(key uint64) bool {
+	if len(f.fingerprints) == 0 {
+		return false
+	}
+	h := mixKey(key, f.Seed)
+	h0, h1, h2 := xorPos(h, f.blockLength)
+	return uint8(fingerprint(h)) == f.fingerprints[h0]^f.fingerprints[h1]^f.fingerprints[h2]
+}
+
+// ContainsString reports whether s may be in the filter.
+func (f *Xor8) ContainsString(s string) bool {
+	return f.Contains(StringKey(s))
+}
+
+// SizeInBytes returns the size of the fingerprint table.
+func (f *Xor8) SizeInBytes() int { return len(f.fingerprints) }
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (f *Xor8) MarshalBinary() ([]byte, error) {
+	return marshal(magicXor8, f.Seed, []uint32{f.blockLength}, f.fingerprints, nil), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (f *Xor8) UnmarshalBinary(b []byte) error {
+	var params [1]uint32
+	b, seed, err := unmarshalHeader(b, magicXor8, params[:])
+	if err != nil {
+		return err
+	}
+	if bl := params[0]; bl == 0 || uint64(len(b)) != 3*uint64(bl) {
+		return errInvalidSize
+	}
+	f.Seed, f.blockLength, f.fingerprints = seed, params[0], append([]uint8(nil), b...)
+	return nil
+}
+
+// Xor16 is an xor filter with 16bit fingerprints, it uses about 19.7 bits per key
+// and has a false positive rate of about 0.0015%.
+type Xor16 struct {
+	// Seed is the starting seed for Populate, after a successful Populate it holds the seed that was used.
+	Seed uint64
+
+	blockLength  uint32
+	fingerprints []uint16
+}
+
+// Populate builds the filter from keys, which should already be well distributed, e.g. hashes.
+func (f *Xor16) Populate(keys []uint64) error {
+	keys = uniqueKeys(keys)
+	bl := xorBlockLength(len(keys))
+	pos := xorPositions(bl)
+
+	stack, seed, err := build(keys, f.Seed, 3*bl, pos)
+	if err != nil {
+		return err
+	}
+
+	fps := make([]uint16, 3*bl)
+	assign16(stack, fps, pos)
+
+	f.Seed, f.blockLength, f.fingerprints = seed, bl, fps
+	return nil
+}
+
+// PopulateStrings is a convenience wrapper that populates the filter with StringKey(s) for each key.
+func (f *Xor16) PopulateStrings(keys []string) error {
+	return f.Populate(stringKeys(keys))
+}
+
+// Contains reports whether key may be in the filter.
+func (f *Xor16) Contains(key uint64) bool {
+	if len(f.fingerprints) == 0 {
+		return false
+	}
+	h := mixKey(key, f.Seed)
+	h0, h1, h2 := xorPos(h, f.blockLength)
+	return uint16(fingerprint(h)) == f.fingerprints[h0]^f.fingerprints[h1]^f.fingerprints[h2]
+}
+
+// ContainsString reports whether s may be in the filter.
+func (f *Xor16) ContainsString(s string) bool {
+	return f.Contains(StringKey(s))
+}
+
+// SizeInBytes returns the size of the fingerprint table.
+func (f *Xor16) SizeInBytes() int { return 2 * len(f.fingerprints) }
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (f *Xor16) MarshalBinary() ([]byte, error) {
+	return marshal(magicXor16, f.Seed, []uint32{f.blockLength}, nil, f.fingerprints), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (f *Xor16) UnmarshalBinary(b []byte) error {
+	var params [1]uint32
+	b, seed, err := unmarshalHeader(b, magicXor16, params[:])
+	if err != nil {
+		return err
+	}
+	if params[0] == 0 {
+		return errInvalidSize
+	}
+	fps, err := unmarshal16(b, 3*uint64(params[0]))
+	if err != nil {
+		return err
+	}
+	f.Seed, f.blockLength, f.fingerprints = seed, params[0], fps
+	return nil
+}
+
+func xorBlockLength(n int) uint32 {
+	return uint32(32+(123*uint64(n)+99)/100) / 3
+}
+
+func xorPositions(bl uint32) positionsFn {
+	return func(h uint64) (uint32, uint32, uint32) { return xorPos(h, bl) }
+}
+
+// xorPos maps a hash to one slot in each of the three blocks.
+func xorPos(h uint64, bl uint32) (uint32, uint32, uint32) {
+	return reduce(uint32(h), bl),
+		reduce(uint32(bits.RotateLeft64(h, 21)), bl) + bl,
+		reduce(uint32(bits.RotateLeft64(h, 42)), bl) + 2*bl
+}
+
+func reduce(x, n uint32) uint32 {
+	return uint32(uint64(x) * uint64(n) >> 32)
+}
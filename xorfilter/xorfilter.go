@@ -0,0 +1,219 @@
+// Package xorfilter implements xor and binary fuse filters for static sets, built on seeded XXH64.
+//
+// The filters are immutable once populated, they are smaller and faster than Bloom
+// or cuckoo filters at the same false positive rate, which makes them a good fit for
+// sets that are built once and shipped around.
+//
+// Construction is deterministic: the same key set and starting Seed always produce the same filter.
+// When construction fails, it is retried with a seed derived from the previous one,
+// the seed that succeeded is stored in the filter and in its serialized form.
+package xorfilter
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+const maxIterations = 100
+
+var (
+	// ErrTooManyIterations is returned by Populate when no seed produced a valid filter.
+	ErrTooManyIterations = errors.New("xorfilter: too many iterations")
+
+	errInvalidIdentifier = errors.New("xorfilter: invalid filter identifier")
+	errInvalidSize       = errors.New("xorfilter: invalid filter size")
+)
+
+// StringKey returns the key PopulateStrings and ContainsString use for s.
+func StringKey(s string) uint64 {
+	return xxhash.ChecksumString64S(s, 0)
+}
+
+func stringKeys(keys []string) []uint64 {
+	out := make([]uint64, len(keys))
+	for i, s := range keys {
+		out[i] = StringKey(s)
+	}
+	return out
+}
+
+// mixKey hashes the little endian representation of k with the filter seed.
+func mixKey(k, seed uint64) uint64 {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], k)
+	return xxhash.Checksum64S(b[:], seed)
+}
+
+func fingerprint(h uint64) uint64 { return h ^ h>>32 }
+
+// nextSeed is a splitmix64 step, used to pick a new seed after a failed construction.
+func nextSeed(seed uint64) uint64 {
+	seed += 0x9e3779b97f4a7c15
+	z := seed
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return z ^ z>>31
+}
+
+// uniqueKeys returns a sorted copy of keys without duplicates, duplicates make peeling impossible.
+func uniqueKeys(keys []uint64) []uint64 {
+	out := append([]uint64(nil), keys...)
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+
+	n := 0
+	for i, k := range out {
+		if i == 0 || k != out[n-1] {
+			out[n] = k
+			n++
+		}
+	}
+	return out[:n]
+}
+
+type positionsFn func(h uint64) (uint32, uint32, uint32)
+
+type peeled struct {
+	h    uint64
+	slot uint32
+}
+
+// peel hashes every key with seed and peels the resulting 3-hypergraph.
+// It returns the keys in peeling order, or nil if the graph has a cycle.
+func peel(keys []uint64, seed uint64, size uint32, pos positionsFn) []peeled {
+	var (
+		counts = make([]uint32, size)
+		xors   = make([]uint64, size)
+		queue  = make([]uint32, 0, size)
+		stack  = make([]peeled, 0, len(keys))
+	)
+
+	for _, k := range keys {
+		h := mixKey(k, seed)
+		h0, h1, h2 := pos(h)
+		counts[h0]++
+		counts[h1]++
+		counts[h2]++
+		xors[h0] ^= h
+		xors[h1] ^= h
+		xors[h2] ^= h
+	}
+
+	for i, c := range counts {
+		if c == 1 {
+			queue = append(queue, uint32(i))
+		}
+	}
+
+	for len(queue) > 0 {
+		slot := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if counts[slot] != 1 {
+			continue
+		}
+
+		h := xors[slot]
+		stack = append(stack, peeled{h, slot})
+
+		h0, h1, h2 := pos(h)
+		for _, s := range [3]uint32{h0, h1, h2} {
+			counts[s]--
+			xors[s] ^= h
+			if counts[s] == 1 {
+				queue = append(queue, s)
+			}
+		}
+	}
+
+	if len(stack) != len(keys) {
+		return nil
+	}
+	return stack
+}
+
+// build peels keys, retrying with a new seed on failure, and returns the stack and the seed that worked.
+func build(keys []uint64, seed uint64, size uint32, pos positionsFn) ([]peeled, uint64, error) {
+	for i := 0; i < maxIterations; i++ {
+		if stack := peel(keys, seed, size, pos); stack != nil {
+			return stack, seed, nil
+		}
+		seed = nextSeed(seed)
+	}
+	return nil, 0, ErrTooManyIterations
+}
+
+// assign8 and assign16 walk the stack in reverse so each key's slot is set last,
+// making the xor of its three fingerprints equal to its fingerprint.
+
+func assign8(stack []peeled, fps []uint8, pos positionsFn) {
+	for i := len(stack) - 1; i >= 0; i-- {
+		e := stack[i]
+		h0, h1, h2 := pos(e.h)
+		fps[e.slot] = uint8(fingerprint(e.h)) ^ fps[h0] ^ fps[h1] ^ fps[h2]
+	}
+}
+
+func assign16(stack []peeled, fps []uint16, pos positionsFn) {
+	for i := len(stack) - 1; i >= 0; i-- {
+		e := stack[i]
+		h0, h1, h2 := pos(e.h)
+		fps[e.slot] = uint16(fingerprint(e.h)) ^ fps[h0] ^ fps[h1] ^ fps[h2]
+	}
+}
+
+// The serialized form is a magic header, the seed, the filter parameters
+// and the fingerprints, all little endian.
+
+func marshal(magic string, seed uint64, params []uint32, fps8 []uint8, fps16 []uint16) []byte {
+	b := make([]byte, 0, len(magic)+8+4*len(params)+len(fps8)+2*len(fps16))
+	b = append(b, magic...)
+	b = appendUint64(b, seed)
+	for _, p := range params {
+		b = appendUint32(b, p)
+	}
+	b = append(b, fps8...)
+	for _, v := range fps16 {
+		b = append(b, byte(v), byte(v>>8))
+	}
+	return b
+}
+
+func unmarshalHeader(b []byte, magic string, params []uint32) (rest []byte, seed uint64, err error) {
+	if len(b) < len(magic) || string(b[:len(magic)]) != magic {
+		return nil, 0, errInvalidIdentifier
+	}
+	if len(b) < len(magic)+8+4*len(params) {
+		return nil, 0, errInvalidSize
+	}
+	b = b[len(magic):]
+	seed, b = binary.LittleEndian.Uint64(b), b[8:]
+	for i := range params {
+		params[i], b = binary.LittleEndian.Uint32(b), b[4:]
+	}
+	return b, seed, nil
+}
+
+func unmarshal16(b []byte, n uint64) ([]uint16, error) {
+	if uint64(len(b)) != 2*n {
+		return nil, errInvalidSize
+	}
+	fps := make([]uint16, n)
+	for i := range fps {
+		fps[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return fps, nil
+}
+
+func appendUint32(b []byte, x uint32) []byte {
+	var a [4]byte
+	binary.LittleEndian.PutUint32(a[:], x)
+	return append(b, a[:]...)
+}
+
+func appendUint64(b []byte, x uint64) []byte {
+	var a [8]byte
+	binary.LittleEndian.PutUint64(a[:], x)
+	return append(b, a[:]...)
+}
@@ -0,0 +1,169 @@
+package xorfilter_test
+
+import (
+	"bytes"
+	"encoding"
+	"strconv"
+	"testing"
+
+	"github.com/OneOfOne/xxhash"
+	"github.com/OneOfOne/xxhash/xorfilter"
+)
+
+type filter interface {
+	Populate(keys []uint64) error
+	PopulateStrings(keys []string) error
+	Contains(key uint64) bool
+	ContainsString(s string) bool
+	SizeInBytes() int
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+var filters = []struct {
+	name   string
+	new    func(seed uint64) filter
+	maxFPR float64
+}{
+	{"Xor8", func(seed uint64) filter { return &xorfilter.Xor8{Seed: seed} }, 0.006},
+	{"Xor16", func(seed uint64) filter { return &xorfilter.Xor16{Seed: seed} }, 0.0002},
+	{"BinaryFuse8", func(seed uint64) filter { return &xorfilter.BinaryFuse8{Seed: seed} }, 0.006},
+	{"BinaryFuse16", func(seed uint64) filter { return &xorfilter.BinaryFuse16{Seed: seed} }, 0.0002},
+}
+
+func makeKeys(n int, prefix string) []uint64 {
+	keys := make([]uint64, n)
+	for i := range keys {
+		keys[i] = xxhash.ChecksumString64(prefix + strconv.Itoa(i))
+	}
+	return keys
+}
+
+func TestFilters(t *testing.T) {
+	const N = 100000
+	keys, others := makeKeys(N, "in-"), makeKeys(N, "out-")
+	for _, tc := range filters {
+		t.Run(tc.name, func(t *testing.T) {
+			f := tc.new(1)
+			if err := f.Populate(keys); err != nil {
+				t.Fatal(err)
+			}
+			for _, k := range keys {
+				if !f.Contains(k) {
+					t.Fatalf("false negative for %x", k)
+				}
+			}
+			var fp int
+			for _, k := range others {
+				if f.Contains(k) {
+					fp++
+				}
+			}
+			if rate := float64(fp) / N; rate > tc.maxFPR {
+				t.Fatalf("false positive rate too high: %f", rate)
+			}
+			t.Logf("bits per key: %.2f, false positive rate: %f", float64(f.SizeInBytes()*8)/N, float64(fp)/N)
+		})
+	}
+}
+
+func TestSmallAndDuplicates(t *testing.T) {
+	for _, tc := range filters {
+		t.Run(tc.name, func(t *testing.T) {
+			for n := 0; n < 64; n++ {
+				keys := makeKeys(n, "")
+				keys = append(keys, keys...)
+				f := tc.new(0)
+				if err := f.Populate(keys); err != nil {
+					t.Fatalf("n=%d: %v", n, err)
+				}
+				for _, k := range keys {
+					if !f.Contains(k) {
+						t.Fatalf("n=%d: false negative for %x", n, k)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestStrings(t *testing.T) {
+	domains := []string{"example.com", "example.org", "ads.example.net", "tracker.example.io"}
+	for _, tc := range filters {
+		t.Run(tc.name, func(t *testing.T) {
+			f := tc.new(0)
+			if err := f.PopulateStrings(domains); err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range domains {
+				if !f.ContainsString(d) || !f.Contains(xorfilter.StringKey(d)) {
+					t.Fatalf("false negative for %q", d)
+				}
+			}
+		})
+	}
+}
+
+func TestDeterministicAndMarshaling(t *testing.T) {
+	keys := makeKeys(5000, "k")
+	for _, tc := range filters {
+		t.Run(tc.name, func(t *testing.T) {
+			a, b := tc.new(42), tc.new(42)
+			a.Populate(keys)
+
+			// same set in a different order.
+			rev := make([]uint64, len(keys))
+			for i, k := range keys {
+				rev[len(keys)-1-i] = k
+			}
+			b.Populate(rev)
+
+			ab, _ := a.MarshalBinary()
+			bb, _ := b.MarshalBinary()
+			if !bytes.Equal(ab, bb) {
+				t.Fatal("same keys and seed produced different filters")
+			}
+
+			c := tc.new(0)
+			if err := c.UnmarshalBinary(ab); err != nil {
+				t.Fatal(err)
+			}
+			for _, k := range keys {
+				if !c.Contains(k) {
+					t.Fatalf("false negative for %x after UnmarshalBinary", k)
+				}
+			}
+			if cb, _ := c.MarshalBinary(); !bytes.Equal(ab, cb) {
+				t.Fatal("round trip changed the serialized form")
+			}
+			if err := c.UnmarshalBinary(ab[:len(ab)-1]); err == nil {
+				t.Fatal("expected an error for a truncated filter")
+			}
+		})
+	}
+}
+
+func BenchmarkPopulate(b *testing.B) {
+	keys := makeKeys(1000000, "")
+	for _, tc := range filters {
+		b.Run(tc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tc.new(0).Populate(keys)
+			}
+		})
+	}
+}
+
+func BenchmarkContains(b *testing.B) {
+	keys := makeKeys(1000000, "")
+	for _, tc := range filters {
+		b.Run(tc.name, func(b *testing.B) {
+			f := tc.new(0)
+			f.Populate(keys)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				f.Contains(keys[i%len(keys)])
+			}
+		})
+	}
+}
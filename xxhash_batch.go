@@ -0,0 +1,190 @@
+package xxhash
+
+// Checksum64Batch sets out[i] to Checksum64S(keys[i], seed), out must be at least as long as keys.
+//
+// Keys under 32 bytes are hashed 4 at a time with keys of the same length,
+// longer keys 2 at a time with keys of the same length modulo 32, so the CPU can overlap
+// their dependency chains and every key of a group takes the same branches.
+func Checksum64Batch(keys [][]byte, seed uint64, out []uint64) {
+	out = out[:len(keys):len(keys)]
+
+	var (
+		short  [32][4]int // indexes of the pending keys under 32 bytes, by length
+		nshort [32]int
+		long   [32]int // index+1 of the pending key of 32 bytes or more, by length modulo 32
+	)
+	for i := 0; i < len(keys); i++ {
+		k := keys[i]
+
+		// runs of keys of the same length skip the pending keys.
+		if len(k) >= 32 {
+			if i+1 < len(keys) && len(keys[i+1]) == len(k) {
+				out[i], out[i+1] = checksum64x2(k, keys[i+1], seed)
+				i++
+				continue
+			}
+
+			p := &long[len(k)&31]
+			if *p == 0 {
+				*p = i + 1
+				continue
+			}
+			out[*p-1], out[i] = checksum64x2(keys[*p-1], k, seed)
+			*p = 0
+			continue
+		}
+
+		if g := keys[i:]; len(g) >= 4 && len(g[1]) == len(k) && len(g[2]) == len(k) && len(g[3]) == len(k) {
+			out[i], out[i+1], out[i+2], out[i+3] = checksum64Shortx4(k, g[1], g[2], g[3], seed)
+			i += 3
+			continue
+		}
+
+		q, n := &short[len(k)], &nshort[len(k)]
+		if q[*n&3] = i; *n < 3 {
+			*n++
+			continue
+		}
+		out[q[0]], out[q[1]], out[q[2]], out[q[3]] = checksum64Shortx4(keys[q[0]], keys[q[1]], keys[q[2]], k, seed)
+		*n = 0
+	}
+
+	// the keys left over didn't fill a group.
+	for _, i := range long {
+		if i > 0 {
+			out[i-1] = checksum64(keys[i-1], seed)
+		}
+	}
+	for l, q := range short {
+		for _, i := range q[:nshort[l]] {
+			out[i] = Checksum64S(keys[i], seed)
+		}
+	}
+}
+
+// ChecksumString64Batch sets out[i] to ChecksumString64S(keys[i], seed), out must be at least as long as keys.
+func ChecksumString64Batch(keys []string, seed uint64, out []uint64) {
+	out = out[:len(keys):len(keys)]
+
+	var buf [256][]byte
+	for len(keys) > 0 {
+		n := stringsBytes(buf[:], keys)
+		Checksum64Batch(buf[:n], seed, out[:n])
+		keys, out = keys[n:], out[n:]
+	}
+}
+
+// Checksum64Stride hashes the keys packed back to back in buf, every key is stride bytes long.
+// It sets out[i] to Checksum64S(buf[i*stride:(i+1)*stride], seed) for every whole key in buf,
+// and returns the number of keys, out must be long enough to hold them.
+func Checksum64Stride(buf []byte, stride int, seed uint64, out []uint64) int {
+	if stride <= 0 {
+		panic("xxhash: invalid stride")
+	}
+	n := len(buf) / stride
+	out = out[:n:n]
+
+	if stride >= 32 {
+		i := 0
+		for ; i+2 <= n; i += 2 {
+			out[i], out[i+1] = checksum64x2(buf[i*stride:(i+1)*stride], buf[(i+1)*stride:(i+2)*stride], seed)
+		}
+		if i < n {
+			out[i] = checksum64(buf[i*stride:(i+1)*stride], seed)
+		}
+		return n
+	}
+
+	i := 0
+	for ; i+4 <= n; i += 4 {
+		keys := buf[i*stride : (i+4)*stride]
+		out[i], out[i+1], out[i+2], out[i+3] = checksum64Shortx4(
+			keys[:stride], keys[stride:2*stride], keys[2*stride:3*stride], keys[3*stride:], seed)
+	}
+	for ; i < n; i++ {
+		out[i] = checksum64Short(buf[i*stride:(i+1)*stride], seed)
+	}
+	return n
+}
+
+// checksum64Shortx4 is checksum64Short for 4 inputs of the same length under 32 bytes.
+func checksum64Shortx4(a, b, c, d []byte, seed uint64) (ha, hb, hc, hd uint64) {
+	b, c, d = b[:len(a)], c[:len(a)], d[:len(a)]
+
+	ha = seed + prime64x5 + uint64(len(a))
+	hb, hc, hd = ha, ha, ha
+
+	i := 0
+	for ; i+8 <= len(a); i += 8 {
+		ha = rotl64_27(ha^round64(0, u64(a[i:i+8:len(a)])))*prime64x1 + prime64x4
+		hb = rotl64_27(hb^round64(0, u64(b[i:i+8:len(b)])))*prime64x1 + prime64x4
+		hc = rotl64_27(hc^round64(0, u64(c[i:i+8:len(c)])))*prime64x1 + prime64x4
+		hd = rotl64_27(hd^round64(0, u64(d[i:i+8:len(d)])))*prime64x1 + prime64x4
+	}
+	if i+4 <= len(a) {
+		ha = rotl64_23(ha^uint64(u32(a[i:i+4:len(a)]))*prime64x1)*prime64x2 + prime64x3
+		hb = rotl64_23(hb^uint64(u32(b[i:i+4:len(b)]))*prime64x1)*prime64x2 + prime64x3
+		hc = rotl64_23(hc^uint64(u32(c[i:i+4:len(c)]))*prime64x1)*prime64x2 + prime64x3
+		hd = rotl64_23(hd^uint64(u32(d[i:i+4:len(d)]))*prime64x1)*prime64x2 + prime64x3
+		i += 4
+	}
+	for ; i < len(a); i++ {
+		ha = rotl64_11(ha^uint64(a[i])*prime64x5) * prime64x1
+		hb = rotl64_11(hb^uint64(b[i])*prime64x5) * prime64x1
+		hc = rotl64_11(hc^uint64(c[i])*prime64x5) * prime64x1
+		hd = rotl64_11(hd^uint64(d[i])*prime64x5) * prime64x1
+	}
+
+	return mix64(ha), mix64(hb), mix64(hc), mix64(hd)
+}
+
+// checksum64x2 is checksum64 for 2 inputs of at least 32 bytes and of the same length modulo 32.
+// The stripes are hashed one input after the other, the tails and the final mix together.
+func checksum64x2(a, b []byte, seed uint64) (ha, hb uint64) {
+	var (
+		a1, a2, a3, a4 = resetVs64(seed)
+		b1, b2, b3, b4 = a1, a2, a3, a4
+
+		la, lb = len(a), len(b)
+	)
+
+	for ; len(a) >= 32; a = a[32:] {
+		s := (*[32]byte)(a)
+		a1 = round64(a1, u64(s[0:8]))
+		a2 = round64(a2, u64(s[8:16]))
+		a3 = round64(a3, u64(s[16:24]))
+		a4 = round64(a4, u64(s[24:32]))
+	}
+	for ; len(b) >= 32; b = b[32:] {
+		s := (*[32]byte)(b)
+		b1 = round64(b1, u64(s[0:8]))
+		b2 = round64(b2, u64(s[8:16]))
+		b3 = round64(b3, u64(s[16:24]))
+		b4 = round64(b4, u64(s[24:32]))
+	}
+	b = b[:len(a)]
+
+	ha = rotl64_1(a1) + rotl64_7(a2) + rotl64_12(a3) + rotl64_18(a4)
+	hb = rotl64_1(b1) + rotl64_7(b2) + rotl64_12(b3) + rotl64_18(b4)
+	ha = mergeRound64(mergeRound64(mergeRound64(mergeRound64(ha, a1), a2), a3), a4)
+	hb = mergeRound64(mergeRound64(mergeRound64(mergeRound64(hb, b1), b2), b3), b4)
+	ha += uint64(la)
+	hb += uint64(lb)
+
+	i := 0
+	for ; i+8 <= len(a); i += 8 {
+		ha = rotl64_27(ha^round64(0, u64(a[i:i+8:len(a)])))*prime64x1 + prime64x4
+		hb = rotl64_27(hb^round64(0, u64(b[i:i+8:len(b)])))*prime64x1 + prime64x4
+	}
+	if i+4 <= len(a) {
+		ha = rotl64_23(ha^uint64(u32(a[i:i+4:len(a)]))*prime64x1)*prime64x2 + prime64x3
+		hb = rotl64_23(hb^uint64(u32(b[i:i+4:len(b)]))*prime64x1)*prime64x2 + prime64x3
+		i += 4
+	}
+	for ; i < len(a); i++ {
+		ha = rotl64_11(ha^uint64(a[i])*prime64x5) * prime64x1
+		hb = rotl64_11(hb^uint64(b[i])*prime64x5) * prime64x1
+	}
+
+	return mix64(ha), mix64(hb)
+}
@@ -0,0 +1,132 @@
+package xxhash_test
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+func TestChecksum64Batch(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, seed := range []uint64{0, 42} {
+		var (
+			keys []([]byte)
+			strs []string
+		)
+		// runs of equal lengths, then mixed lengths, with leftovers that don't fill a group.
+		for n := 0; n < 70; n++ {
+			for j := 0; j < 4+n%3; j++ {
+				k := make([]byte, n)
+				rnd.Read(k)
+				keys = append(keys, k)
+				strs = append(strs, string(k))
+			}
+		}
+		for j := 0; j < 999; j++ {
+			k := make([]byte, rnd.Intn(130))
+			rnd.Read(k)
+			keys = append(keys, k)
+			strs = append(strs, string(k))
+		}
+
+		out := make([]uint64, len(keys))
+		xxhash.Checksum64Batch(keys, seed, out)
+		for i, k := range keys {
+			if want := xxhash.Checksum64S(k, seed); out[i] != want {
+				t.Fatalf("seed %d, key %d (len %d): got 0x%x; want 0x%x", seed, i, len(k), out[i], want)
+			}
+		}
+
+		sout := make([]uint64, len(strs))
+		xxhash.ChecksumString64Batch(strs, seed, sout)
+		for i := range strs {
+			if sout[i] != out[i] {
+				t.Fatalf("seed %d, string key %d: got 0x%x; want 0x%x", seed, i, sout[i], out[i])
+			}
+		}
+	}
+}
+
+func TestChecksum64Stride(t *testing.T) {
+	buf := make([]byte, 4099)
+	rand.New(rand.NewSource(2)).Read(buf)
+	out := make([]uint64, len(buf))
+	for stride := 1; stride <= 70; stride++ {
+		n := xxhash.Checksum64Stride(buf, stride, 7, out)
+		if n != len(buf)/stride {
+			t.Fatalf("stride %d: expected %d keys, got %d", stride, len(buf)/stride, n)
+		}
+		for i := 0; i < n; i++ {
+			if want := xxhash.Checksum64S(buf[i*stride:(i+1)*stride], 7); out[i] != want {
+				t.Fatalf("stride %d, key %d: got 0x%x; want 0x%x", stride, i, out[i], want)
+			}
+		}
+	}
+}
+
+// BenchmarkChecksum64BatchMixed hashes keys of random lengths between 8 and 64 bytes.
+func BenchmarkChecksum64BatchMixed(b *testing.B) {
+	var (
+		rnd   = rand.New(rand.NewSource(3))
+		keys  = make([][]byte, 1024)
+		out   = make([]uint64, len(keys))
+		total int
+	)
+	for i := range keys {
+		keys[i] = make([]byte, 8+rnd.Intn(57))
+		rnd.Read(keys[i])
+		total += len(keys[i])
+	}
+
+	b.Run("Loop", func(b *testing.B) {
+		b.SetBytes(int64(total))
+		for i := 0; i < b.N; i++ {
+			for j, k := range keys {
+				out[j] = xxhash.Checksum64S(k, 0)
+			}
+		}
+	})
+	b.Run("Batch", func(b *testing.B) {
+		b.SetBytes(int64(total))
+		for i := 0; i < b.N; i++ {
+			xxhash.Checksum64Batch(keys, 0, out)
+		}
+	})
+}
+
+func BenchmarkChecksum64Batch(b *testing.B) {
+	for _, n := range []int{8, 16, 24, 64} {
+		keys := make([][]byte, 1024)
+		for i := range keys {
+			keys[i] = []byte(strconv.FormatInt(1e15+int64(i), 10) + "........................................................")[:n]
+		}
+		out := make([]uint64, len(keys))
+		b.Run("Loop/"+strconv.Itoa(n), func(b *testing.B) {
+			b.SetBytes(int64(len(keys) * n))
+			for i := 0; i < b.N; i++ {
+				for j, k := range keys {
+					out[j] = xxhash.Checksum64S(k, 0)
+				}
+			}
+		})
+		b.Run("Batch/"+strconv.Itoa(n), func(b *testing.B) {
+			b.SetBytes(int64(len(keys) * n))
+			for i := 0; i < b.N; i++ {
+				xxhash.Checksum64Batch(keys, 0, out)
+			}
+		})
+
+		buf := make([]byte, 0, len(keys)*n)
+		for _, k := range keys {
+			buf = append(buf, k...)
+		}
+		b.Run("Stride/"+strconv.Itoa(n), func(b *testing.B) {
+			b.SetBytes(int64(len(buf)))
+			for i := 0; i < b.N; i++ {
+				xxhash.Checksum64Stride(buf, n, 0, out)
+			}
+		})
+	}
+}
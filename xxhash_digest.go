@@ -0,0 +1,206 @@
+package xxhash
+
+import (
+	"errors"
+	"strings"
+)
+
+// Algorithm is a set of checksum algorithms computed by a MultiDigest.
+type Algorithm uint8
+
+const (
+	// XXH32 selects the 32bit xxHash checksum, as used by the LZ4 frame format.
+	XXH32 Algorithm = 1 << iota
+	// XXH64 selects the 64bit xxHash checksum.
+	XXH64
+
+	allAlgorithms = XXH32 | XXH64
+)
+
+func (a Algorithm) String() string {
+	var names []string
+	if a&XXH32 != 0 {
+		names = append(names, "XXH32")
+	}
+	if a&XXH64 != 0 {
+		names = append(names, "XXH64")
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+const (
+	magicMulti         = "xxmd\x01"
+	multiDigestBufSize = 4 << 10
+)
+
+// ErrInvalidAlgorithm is returned when an Algorithm has no or unknown bits set.
+var ErrInvalidAlgorithm = errors.New("xxhash: invalid algorithm")
+
+// Digests holds the results of a MultiDigest, fields of algorithms that weren't selected are 0.
+type Digests struct {
+	Algorithms Algorithm
+	XXH32      uint32
+	XXH64      uint64
+}
+
+// MultiDigest computes a set of checksums of the same data in one pass,
+// partial blocks are staged once and whole blocks are fed to every selected algorithm while they're still in cache.
+type MultiDigest struct {
+	algs   Algorithm
+	h32    XXHash32
+	h64    XXHash64
+	mem    [32]byte // a multiple of both block sizes
+	memIdx int
+}
+
+// NewMultiDigest returns a MultiDigest computing algs with the seed set to 0x0.
+func NewMultiDigest(algs Algorithm) (*MultiDigest, error) {
+	if algs == 0 || algs&^allAlgorithms != 0 {
+		return nil, ErrInvalidAlgorithm
+	}
+	md := &MultiDigest{algs: algs}
+	md.Reset()
+	return md, nil
+}
+
+// Algorithms returns the algorithms md computes.
+func (md *MultiDigest) Algorithms() Algorithm { return md.algs }
+
+// Reset resets md to its initial state.
+func (md *MultiDigest) Reset() {
+	md.h32 = XXHash32{}
+	md.h32.Reset()
+	md.h64 = XXHash64{}
+	md.h64.Reset()
+	md.memIdx = 0
+}
+
+func (md *MultiDigest) Write(in []byte) (n int, err error) {
+	n = len(in)
+
+	if md.memIdx+len(in) < len(md.mem) {
+		md.memIdx += copy(md.mem[md.memIdx:], in)
+		return
+	}
+
+	if md.memIdx > 0 {
+		d := copy(md.mem[md.memIdx:], in)
+		md.writeBlocks(md.mem[:])
+		in, md.memIdx = in[d:], 0
+	}
+
+	blocks := len(in) &^ (len(md.mem) - 1)
+	for b := in[:blocks]; len(b) > 0; {
+		c := b
+		if len(c) > multiDigestBufSize {
+			c = c[:multiDigestBufSize]
+		}
+		md.writeBlocks(c)
+		b = b[len(c):]
+	}
+	md.memIdx = copy(md.mem[:], in[blocks:])
+	return
+}
+
+// WriteString is like Write but takes a string.
+func (md *MultiDigest) WriteString(s string) (int, error) {
+	return md.Write([]byte(s))
+}
+
+// writeBlocks feeds whole blocks to the selected hashes, so they never have to stage anything themselves.
+func (md *MultiDigest) writeBlocks(in []byte) {
+	if md.algs&XXH32 != 0 {
+		md.h32.Write(in)
+	}
+	if md.algs&XXH64 != 0 {
+		md.h64.Write(in)
+	}
+}
+
+// Sum returns the checksums of the data written so far.
+// It does not change the underlying hash state.
+func (md *MultiDigest) Sum() Digests {
+	d := Digests{Algorithms: md.algs}
+	tail := md.mem[:md.memIdx]
+	if md.algs&XXH32 != 0 {
+		h := md.h32
+		h.Write(tail)
+		d.XXH32 = h.Sum32()
+	}
+	if md.algs&XXH64 != 0 {
+		h := md.h64
+		h.Write(tail)
+		d.XXH64 = h.Sum64()
+	}
+	return d
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+//
+// The format is a magic header, the selected algorithms, the state of each selected hash
+// in the same format as its own MarshalBinary, then the staged partial block.
+func (md *MultiDigest) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, len(magicMulti)+1+marshaled32Size+marshaled64Size+1+len(md.mem))
+	b = append(b, magicMulti...)
+	b = append(b, byte(md.algs))
+	if md.algs&XXH32 != 0 {
+		s, _ := md.h32.MarshalBinary()
+		b = append(b, s...)
+	}
+	if md.algs&XXH64 != 0 {
+		s, _ := md.h64.MarshalBinary()
+		b = append(b, s...)
+	}
+	b = append(b, byte(md.memIdx))
+	b = append(b, md.mem[:md.memIdx]...)
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (md *MultiDigest) UnmarshalBinary(b []byte) error {
+	if len(b) < len(magicMulti) || string(b[:len(magicMulti)]) != magicMulti {
+		return errors.New("xxhash: invalid hash state identifier")
+	}
+	b = b[len(magicMulti):]
+	if len(b) < 1 {
+		return errors.New("xxhash: invalid hash state size")
+	}
+
+	var nmd MultiDigest
+	nmd.algs = Algorithm(b[0])
+	if nmd.algs == 0 || nmd.algs&^allAlgorithms != 0 {
+		return ErrInvalidAlgorithm
+	}
+	nmd.Reset()
+	b = b[1:]
+
+	if nmd.algs&XXH32 != 0 {
+		if len(b) < marshaled32Size {
+			return errors.New("xxhash: invalid hash state size")
+		}
+		if err := nmd.h32.UnmarshalBinary(b[:marshaled32Size]); err != nil {
+			return err
+		}
+		b = b[marshaled32Size:]
+	}
+	if nmd.algs&XXH64 != 0 {
+		if len(b) < marshaled64Size {
+			return errors.New("xxhash: invalid hash state size")
+		}
+		if err := nmd.h64.UnmarshalBinary(b[:marshaled64Size]); err != nil {
+			return err
+		}
+		b = b[marshaled64Size:]
+	}
+
+	if len(b) < 1 || int(b[0]) >= len(nmd.mem) || len(b) != 1+int(b[0]) {
+		return errors.New("xxhash: invalid hash state size")
+	}
+	nmd.memIdx = copy(nmd.mem[:], b[1:])
+
+	*md = nmd
+	return nil
+}
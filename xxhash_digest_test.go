@@ -0,0 +1,95 @@
+package xxhash_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+func TestMultiDigest(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	data := make([]byte, 10000)
+	rnd.Read(data)
+
+	for _, n := range []int{0, 1, 15, 16, 31, 32, 33, 100, 4096, 4129, len(data)} {
+		in := data[:n]
+		md, err := xxhash.NewMultiDigest(xxhash.XXH32 | xxhash.XXH64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rest := in; len(rest) > 0; {
+			c := rnd.Intn(5000) + 1
+			if c > len(rest) {
+				c = len(rest)
+			}
+			md.Write(rest[:c])
+			rest = rest[c:]
+		}
+		d := md.Sum()
+		if d.XXH32 != xxhash.Checksum32(in) || d.XXH64 != xxhash.Checksum64(in) {
+			t.Fatalf("len %d: got %08x/%016x; want %08x/%016x", n, d.XXH32, d.XXH64, xxhash.Checksum32(in), xxhash.Checksum64(in))
+		}
+	}
+}
+
+func TestMultiDigestSubset(t *testing.T) {
+	md, _ := xxhash.NewMultiDigest(xxhash.XXH64)
+	md.WriteString(inS)
+	if d := md.Sum(); d.XXH32 != 0 || d.XXH64 != xxhash.ChecksumString64(inS) || d.Algorithms != xxhash.XXH64 {
+		t.Fatalf("unexpected digests: %+v", d)
+	}
+	if _, err := xxhash.NewMultiDigest(0); err != xxhash.ErrInvalidAlgorithm {
+		t.Fatalf("expected ErrInvalidAlgorithm, got %v", err)
+	}
+	if s := (xxhash.XXH32 | xxhash.XXH64).String(); s != "XXH32|XXH64" {
+		t.Fatalf("unexpected String(): %q", s)
+	}
+}
+
+func TestMultiDigestMarshaling(t *testing.T) {
+	for _, algs := range []xxhash.Algorithm{xxhash.XXH32, xxhash.XXH64, xxhash.XXH32 | xxhash.XXH64} {
+		for _, split := range []int{0, 7, 32, 100, len(inS)} {
+			md, _ := xxhash.NewMultiDigest(algs)
+			md.WriteString(inS[:split])
+			b, err := md.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var nmd xxhash.MultiDigest
+			if err := nmd.UnmarshalBinary(b); err != nil {
+				t.Fatalf("%v/%d: %v", algs, split, err)
+			}
+			md.WriteString(inS[split:])
+			nmd.WriteString(inS[split:])
+			if md.Sum() != nmd.Sum() {
+				t.Fatalf("%v/%d: got %+v; want %+v", algs, split, nmd.Sum(), md.Sum())
+			}
+
+			if err := nmd.UnmarshalBinary(b[:len(b)-1]); err == nil {
+				t.Fatalf("%v/%d: expected an error for a truncated state", algs, split)
+			}
+		}
+	}
+}
+
+func BenchmarkMultiDigest(b *testing.B) {
+	buf := make([]byte, 1<<20)
+	b.Run("Separate", func(b *testing.B) {
+		b.SetBytes(int64(len(buf)))
+		for i := 0; i < b.N; i++ {
+			xxhash.Checksum32(buf)
+			xxhash.Checksum64(buf)
+		}
+	})
+	b.Run("MultiDigest", func(b *testing.B) {
+		md, _ := xxhash.NewMultiDigest(xxhash.XXH32 | xxhash.XXH64)
+		b.SetBytes(int64(len(buf)))
+		for i := 0; i < b.N; i++ {
+			md.Reset()
+			md.Write(buf)
+			md.Sum()
+		}
+	})
+}
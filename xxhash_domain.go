@@ -0,0 +1,101 @@
+package xxhash
+
+// DeriveSeed returns a seed for label derived from parent, so different uses of the same parent seed
+// get independent seeds instead of ad-hoc ones like 1, 2 or 42.
+//
+// It is the XXH64 checksum, with seed parent, of the 8 bytes "derive\x00\xff", the 8 byte little endian
+// length of label and label. As a label length the tag would be over 2^63, so a derived seed is never
+// the checksum of a Domain input with the same parent seed.
+func DeriveSeed(parent uint64, label string) uint64 {
+	b := make([]byte, 0, len(deriveTag)+8+len(label))
+	b = append(b, deriveTag...)
+	b = appendUint64(b, uint64(len(label)))
+	b = append(b, label...)
+	return Checksum64S(b, parent)
+}
+
+const deriveTag = "derive\x00\xff"
+
+// Derive returns a new secret seed for label derived from seed, see DeriveSeed.
+func (seed Seed) Derive(label string) Seed {
+	s := DeriveSeed(seed.value(), label)
+	if s == 0 {
+		s = 1 // keep the derived seed valid, a 1 in 2^64 event
+	}
+	return Seed{s}
+}
+
+// Domain hashes inputs prefixed with a length delimited label, so checksums computed in
+// different domains never collide by construction, only by chance.
+//
+// The checksum of in is the XXH64 checksum, with the domain's seed, of the 8 byte little endian
+// length of the label, the label, then in. A Domain is safe for concurrent use.
+type Domain struct {
+	label string
+	h     XXHash64 // state after writing the prefix
+}
+
+// NewDomain returns a Domain for label with the seed set to 0x0.
+func NewDomain(label string) *Domain {
+	return NewDomainS(label, 0)
+}
+
+// NewDomainS returns a Domain for label with the specific seed.
+func NewDomainS(label string, seed uint64) *Domain {
+	d := &Domain{label: label}
+	d.h.seed = seed
+	d.h.Reset()
+
+	d.h.Write(appendUint64(make([]byte, 0, 8), uint64(len(label))))
+	d.h.WriteString(label)
+	return d
+}
+
+// Label returns the domain's label.
+func (d *Domain) Label() string { return d.label }
+
+// Checksum64 returns the checksum of in within the domain.
+func (d *Domain) Checksum64(in []byte) uint64 {
+	h := d.h
+	h.Write(in)
+	return h.Sum64()
+}
+
+// ChecksumString64 is like Checksum64 but takes a string.
+func (d *Domain) ChecksumString64(s string) uint64 {
+	h := d.h
+	h.WriteString(s)
+	return h.Sum64()
+}
+
+// New64 returns a streaming hash that computes checksums within the domain.
+func (d *Domain) New64() *DomainHash64 {
+	return &DomainHash64{d: d, h: d.h}
+}
+
+// DomainHash64 is a streaming 64bit xxHash within a Domain, see Domain.New64.
+type DomainHash64 struct {
+	d *Domain
+	h XXHash64
+}
+
+// Reset resets the hash to its initial state, keeping the domain's prefix.
+func (xx *DomainHash64) Reset() { xx.h = xx.d.h }
+
+func (xx *DomainHash64) Write(in []byte) (int, error) { return xx.h.Write(in) }
+
+// WriteString is like Write but takes a string.
+func (xx *DomainHash64) WriteString(s string) (int, error) { return xx.h.WriteString(s) }
+
+// Sum64 returns the checksum of the data written so far.
+func (xx *DomainHash64) Sum64() uint64 { return xx.h.Sum64() }
+
+// Sum appends the current hash to b and returns the resulting slice.
+// It does not change the underlying hash state.
+func (xx *DomainHash64) Sum(in []byte) []byte { return xx.h.Sum(in) }
+
+// Size returns the number of bytes Sum will return.
+func (xx *DomainHash64) Size() int { return 8 }
+
+// BlockSize returns the hash's underlying block size.
+func (xx *DomainHash64) BlockSize() int { return 32 }
@@ -0,0 +1,103 @@
+package xxhash_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"testing"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+var _ hash.Hash64 = (*xxhash.DomainHash64)(nil)
+
+func TestDeriveSeedVectors(t *testing.T) {
+	for _, v := range []struct {
+		parent uint64
+		label  string
+		want   uint64
+	}{
+		{0, "", 0x66d3397bf9b225bd},
+		{0, "cache", 0x0e7bc8810c8ff28f},
+		{42, "cache", 0x93cadba3da43888f},
+		{0, "index", 0x9ff65d0caf1139ca},
+	} {
+		if got := xxhash.DeriveSeed(v.parent, v.label); got != v.want {
+			t.Errorf("DeriveSeed(%d, %q) = %#x; want %#x", v.parent, v.label, got, v.want)
+		}
+		buf := make([]byte, 16, 16+len(v.label))
+		copy(buf, "derive\x00\xff")
+		binary.LittleEndian.PutUint64(buf[8:], uint64(len(v.label)))
+		if got := xxhash.Checksum64S(append(buf, v.label...), v.parent); got != v.want {
+			t.Errorf("documented encoding of (%d, %q) = %#x; want %#x", v.parent, v.label, got, v.want)
+		}
+	}
+}
+
+func TestDomainVectors(t *testing.T) {
+	for _, v := range []struct {
+		label string
+		seed  uint64
+		in    string
+		want  uint64
+	}{
+		{"cache", 0, "", 0x1a8b96f1b116ff49},
+		{"cache", 0, "user:1", 0x508c03d3a1f7f37c},
+		{"index", 0, "user:1", 0xef7542f43d47bd97},
+		{"cache", 42, "user:1", 0x32e51e9b8d9c21e1},
+	} {
+		d := xxhash.NewDomainS(v.label, v.seed)
+		if got := d.ChecksumString64(v.in); got != v.want {
+			t.Errorf("%q/%d: ChecksumString64(%q) = %#x; want %#x", v.label, v.seed, v.in, got, v.want)
+		}
+		if got := d.Checksum64([]byte(v.in)); got != v.want {
+			t.Errorf("%q/%d: Checksum64(%q) = %#x; want %#x", v.label, v.seed, v.in, got, v.want)
+		}
+		h := d.New64()
+		h.WriteString(v.in)
+		if got := h.Sum64(); got != v.want {
+			t.Errorf("%q/%d: New64 = %#x; want %#x", v.label, v.seed, got, v.want)
+		}
+		h.Reset()
+		h.WriteString(v.in)
+		if got := h.Sum64(); got != v.want {
+			t.Errorf("%q/%d: New64 after Reset = %#x; want %#x", v.label, v.seed, got, v.want)
+		}
+	}
+}
+
+func TestDomainSeparation(t *testing.T) {
+	// without the length prefix these pairs would hash the same bytes.
+	a, b := xxhash.NewDomain("ab"), xxhash.NewDomain("a")
+	if a.ChecksumString64("c") == b.ChecksumString64("bc") {
+		t.Fatal("domains collided")
+	}
+	if xxhash.NewDomain("").ChecksumString64(inS) == xxhash.ChecksumString64(inS) {
+		t.Fatal("the empty domain should differ from a plain checksum")
+	}
+}
+
+func TestSeedDerive(t *testing.T) {
+	s := xxhash.MakeSeed()
+	if xxhash.ChecksumSeed64(in, s.Derive("a")) == xxhash.ChecksumSeed64(in, s.Derive("b")) {
+		t.Fatal("derived seeds should differ")
+	}
+	if xxhash.ChecksumSeed64(in, s.Derive("a")) != xxhash.ChecksumSeed64(in, s.Derive("a")) {
+		t.Fatal("derived seeds should be stable")
+	}
+	if xxhash.DeriveSeed(0, "cache") == xxhash.NewDomain("cache").Checksum64(nil) {
+		t.Fatal("a derived seed shouldn't be a checksum in the domain with the same label")
+	}
+}
+
+func ExampleDomain() {
+	users := xxhash.NewDomain("users")
+	sessions := xxhash.NewDomain("sessions")
+	fmt.Printf("%#x\n", users.ChecksumString64("42"))
+	fmt.Printf("%#x\n", sessions.ChecksumString64("42"))
+	fmt.Printf("%#x\n", xxhash.DeriveSeed(0, "users"))
+	// Output:
+	// 0xb9ba6332dd9b75c2
+	// 0xa27d6c788a020c31
+	// 0x9cbc28147194c7e9
+}
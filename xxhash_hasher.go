@@ -0,0 +1,178 @@
+package xxhash
+
+import (
+	"math"
+	"reflect"
+	"sync"
+)
+
+// Hasher hashes values of type T with a seed.
+type Hasher[T any] interface {
+	Hash(v T, seed uint64) uint64
+}
+
+type integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+type float interface {
+	~float32 | ~float64
+}
+
+// IntHasher hashes integers without a buffer, the result is equal to
+// Checksum64S of the 8 byte little endian encoding of uint64(v).
+type IntHasher[T integer] struct{}
+
+// Hash returns the checksum of v with the specific seed.
+func (IntHasher[T]) Hash(v T, seed uint64) uint64 { return checksumUint64(uint64(v), seed) }
+
+// FloatHasher hashes floats like IntHasher hashes their float64 bits,
+// after mapping -0 to +0 and every NaN to a single canonical NaN.
+type FloatHasher[T float] struct{}
+
+// Hash returns the checksum of v with the specific seed.
+func (FloatHasher[T]) Hash(v T, seed uint64) uint64 {
+	return checksumUint64(floatBits(float64(v)), seed)
+}
+
+// StringHasher hashes strings with ChecksumString64S, without copying them.
+type StringHasher[T ~string] struct{}
+
+// Hash returns the checksum of v with the specific seed.
+func (StringHasher[T]) Hash(v T, seed uint64) uint64 { return ChecksumString64S(string(v), seed) }
+
+// BytesHasher hashes byte slices with Checksum64S.
+type BytesHasher struct{}
+
+// Hash returns the checksum of v with the specific seed.
+func (BytesHasher) Hash(v []byte, seed uint64) uint64 { return Checksum64S(v, seed) }
+
+// ComparableHasher hashes any comparable value field-wise, it is meant for arrays and structs.
+//
+// Every field is encoded on its own: integers and floats like IntHasher and FloatHasher,
+// bools as one byte, strings with an 8 byte length prefix, pointers and channels by address,
+// interfaces by their dynamic type name followed by their value.
+// Padding between fields is never hashed, so equal values always have equal hashes.
+// It uses reflection and is slower than the typed hashers.
+type ComparableHasher[T comparable] struct{}
+
+var hashBufPool = sync.Pool{New: func() interface{} { b := make([]byte, 0, 256); return &b }}
+
+// Hash returns the checksum of v with the specific seed.
+func (ComparableHasher[T]) Hash(v T, seed uint64) uint64 {
+	bp := hashBufPool.Get().(*[]byte)
+	b := appendComparable((*bp)[:0], reflect.ValueOf(&v).Elem())
+	h := Checksum64S(b, seed)
+	*bp = b
+	hashBufPool.Put(bp)
+	return h
+}
+
+// NewHasher returns the fastest Hasher for T.
+func NewHasher[T comparable]() Hasher[T] {
+	var (
+		zero T
+		h    interface{}
+	)
+	switch interface{}(zero).(type) {
+	case int:
+		h = IntHasher[int]{}
+	case int8:
+		h = IntHasher[int8]{}
+	case int16:
+		h = IntHasher[int16]{}
+	case int32:
+		h = IntHasher[int32]{}
+	case int64:
+		h = IntHasher[int64]{}
+	case uint:
+		h = IntHasher[uint]{}
+	case uint8:
+		h = IntHasher[uint8]{}
+	case uint16:
+		h = IntHasher[uint16]{}
+	case uint32:
+		h = IntHasher[uint32]{}
+	case uint64:
+		h = IntHasher[uint64]{}
+	case uintptr:
+		h = IntHasher[uintptr]{}
+	case float32:
+		h = FloatHasher[float32]{}
+	case float64:
+		h = FloatHasher[float64]{}
+	case string:
+		h = StringHasher[string]{}
+	default:
+		return ComparableHasher[T]{}
+	}
+	return h.(Hasher[T])
+}
+
+// checksumUint64 is Checksum64S for an 8 byte little endian input.
+func checksumUint64(k, seed uint64) uint64 {
+	h := seed + prime64x5 + 8
+	h ^= round64(0, k)
+	h = rotl64_27(h)*prime64x1 + prime64x4
+	return mix64(h)
+}
+
+// canonicalNaN is the bit pattern every NaN is hashed as.
+const canonicalNaN = 0x7ff8000000000001
+
+func floatBits(f float64) uint64 {
+	switch {
+	case f == 0:
+		return 0
+	case f != f:
+		return canonicalNaN
+	}
+	return math.Float64bits(f)
+}
+
+func appendComparable(b []byte, v reflect.Value) []byte {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, 1)
+		}
+		return append(b, 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendUint64(b, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendUint64(b, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return appendUint64(b, floatBits(v.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		b = appendUint64(b, floatBits(real(c)))
+		return appendUint64(b, floatBits(imag(c)))
+	case reflect.String:
+		s := v.String()
+		b = appendUint64(b, uint64(len(s)))
+		return append(b, s...)
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return appendUint64(b, uint64(v.Pointer()))
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			b = appendComparable(b, v.Index(i))
+		}
+		return b
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			b = appendComparable(b, v.Field(i))
+		}
+		return b
+	case reflect.Interface:
+		if v.IsNil() {
+			return append(b, 0)
+		}
+		e := v.Elem()
+		b = append(b, 1)
+		b = appendComparable(b, reflect.ValueOf(e.Type().String()))
+		return appendComparable(b, e)
+	}
+	// not reachable for comparable types.
+	panic("xxhash: can't hash a value of type " + v.Type().String())
+}
@@ -0,0 +1,115 @@
+package xxhash_test
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+func TestIntHasher(t *testing.T) {
+	var b [8]byte
+	for _, seed := range []uint64{0, 1, 0xdeadbeef} {
+		for _, v := range []uint64{0, 1, 0xff, 1 << 40, math.MaxUint64} {
+			binary.LittleEndian.PutUint64(b[:], v)
+			want := xxhash.Checksum64S(b[:], seed)
+			if got := (xxhash.IntHasher[uint64]{}).Hash(v, seed); got != want {
+				t.Fatalf("uint64(%d), seed %d: got 0x%x; want 0x%x", v, seed, got, want)
+			}
+		}
+		if a, b := (xxhash.IntHasher[int8]{}).Hash(-1, seed), (xxhash.IntHasher[int64]{}).Hash(-1, seed); a != b {
+			t.Fatalf("int8(-1) and int64(-1) hash differently: 0x%x, 0x%x", a, b)
+		}
+	}
+}
+
+func TestFloatHasher(t *testing.T) {
+	h := xxhash.FloatHasher[float64]{}
+	if h.Hash(0, 0) != h.Hash(math.Copysign(0, -1), 0) {
+		t.Fatal("-0 and +0 hash differently")
+	}
+	if h.Hash(math.NaN(), 0) != h.Hash(math.Float64frombits(0x7ff0000000000123), 0) {
+		t.Fatal("NaNs hash differently")
+	}
+	if h.Hash(1.5, 0) != (xxhash.FloatHasher[float32]{}).Hash(1.5, 0) {
+		t.Fatal("float32 and float64 hash differently")
+	}
+	if h.Hash(1, 0) == h.Hash(2, 0) {
+		t.Fatal("1 and 2 have the same hash")
+	}
+}
+
+type point struct {
+	X, Y int16
+	Name string
+	F    float32
+	P    *int
+}
+
+func TestComparableHasher(t *testing.T) {
+	h := xxhash.ComparableHasher[point]{}
+	var x, y int
+	a := point{1, 2, "a", 0, &x}
+	b := point{1, 2, "a", float32(math.Copysign(0, -1)), &x}
+	if h.Hash(a, 7) != h.Hash(b, 7) {
+		t.Fatal("equal values hash differently")
+	}
+	for _, c := range []point{{2, 1, "a", 0, &x}, {1, 2, "b", 0, &x}, {1, 2, "a", 0, &y}, {1, 2, "a", 0, nil}} {
+		if h.Hash(a, 7) == h.Hash(c, 7) {
+			t.Fatalf("%+v and %+v have the same hash", a, c)
+		}
+	}
+
+	// strings are length prefixed, so moving bytes between fields changes the hash.
+	type pair struct{ A, B string }
+	ph := xxhash.ComparableHasher[pair]{}
+	if ph.Hash(pair{"ab", "c"}, 0) == ph.Hash(pair{"a", "bc"}, 0) {
+		t.Fatal("field boundaries are ambiguous")
+	}
+
+	ah := xxhash.ComparableHasher[[3]float64]{}
+	if ah.Hash([3]float64{1, math.NaN(), 0}, 0) != ah.Hash([3]float64{1, -math.NaN(), math.Copysign(0, -1)}, 0) {
+		t.Fatal("array elements aren't normalized")
+	}
+}
+
+func TestNewHasher(t *testing.T) {
+	if _, ok := xxhash.NewHasher[int]().(xxhash.IntHasher[int]); !ok {
+		t.Fatal("NewHasher[int] isn't an IntHasher")
+	}
+	if _, ok := xxhash.NewHasher[float32]().(xxhash.FloatHasher[float32]); !ok {
+		t.Fatal("NewHasher[float32] isn't a FloatHasher")
+	}
+	if got, want := xxhash.NewHasher[string]().Hash(inS, 3), xxhash.ChecksumString64S(inS, 3); got != want {
+		t.Fatalf("NewHasher[string]: got 0x%x; want 0x%x", got, want)
+	}
+	if _, ok := xxhash.NewHasher[point]().(xxhash.ComparableHasher[point]); !ok {
+		t.Fatal("NewHasher[point] isn't a ComparableHasher")
+	}
+}
+
+func BenchmarkHasher(b *testing.B) {
+	b.Run("Int", func(b *testing.B) {
+		var h xxhash.IntHasher[uint64]
+		for i := 0; i < b.N; i++ {
+			h.Hash(uint64(i), 0)
+		}
+	})
+	b.Run("Checksum64S", func(b *testing.B) {
+		var buf [8]byte
+		for i := 0; i < b.N; i++ {
+			binary.LittleEndian.PutUint64(buf[:], uint64(i))
+			xxhash.Checksum64S(buf[:], 0)
+		}
+	})
+	b.Run("Comparable", func(b *testing.B) {
+		var (
+			h xxhash.ComparableHasher[point]
+			p = point{1, 2, "name", 3, nil}
+		)
+		for i := 0; i < b.N; i++ {
+			h.Hash(p, 0)
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package xxhash
+
+// XXHash64Multi computes the 64bit xxHash checksum of the same input under several seeds
+// in a single pass over the input, which is useful when the input is expensive to read twice.
+type XXHash64Multi struct {
+	seeds  []uint64
+	vs     []uint64 // 4 lanes per seed
+	ln     uint64
+	mem    [32]byte
+	memIdx int
+}
+
+// NewMulti64 creates a new XXHash64Multi, Sums64()[i] is the checksum with seeds[i].
+func NewMulti64(seeds ...uint64) *XXHash64Multi {
+	xx := &XXHash64Multi{
+		seeds: append([]uint64(nil), seeds...),
+		vs:    make([]uint64, 4*len(seeds)),
+	}
+	xx.Reset()
+	return xx
+}
+
+// Reset resets the hash to its initial state.
+func (xx *XXHash64Multi) Reset() {
+	xx.ln, xx.memIdx = 0, 0
+	for i, seed := range xx.seeds {
+		v := xx.vs[4*i : 4*i+4 : 4*i+4]
+		v[0], v[1], v[2], v[3] = resetVs64(seed)
+	}
+}
+
+// Seeds returns the seeds the hash was created with.
+func (xx *XXHash64Multi) Seeds() []uint64 { return append([]uint64(nil), xx.seeds...) }
+
+// BlockSize returns the hash's underlying block size.
+func (xx *XXHash64Multi) BlockSize() int { return 32 }
+
+func (xx *XXHash64Multi) Write(in []byte) (n int, err error) {
+	n = len(in)
+	xx.ln += uint64(n)
+
+	if xx.memIdx+len(in) < 32 {
+		xx.memIdx += copy(xx.mem[xx.memIdx:], in)
+		return
+	}
+
+	if xx.memIdx > 0 {
+		d := copy(xx.mem[xx.memIdx:], in)
+		roundsMulti(xx.vs, xx.mem[:])
+		in, xx.memIdx = in[d:], 0
+	}
+
+	stripes := len(in) &^ 31
+	if stripes > 0 {
+		roundsMulti(xx.vs, in[:stripes])
+	}
+	xx.memIdx = copy(xx.mem[:], in[stripes:])
+	return
+}
+
+// WriteString is like Write but takes a string.
+func (xx *XXHash64Multi) WriteString(s string) (int, error) {
+	return xx.Write([]byte(s))
+}
+
+// Sums64 returns the checksum of the data written so far under every seed.
+// It does not change the underlying hash state.
+func (xx *XXHash64Multi) Sums64() []uint64 {
+	out := make([]uint64, len(xx.seeds))
+	for i, seed := range xx.seeds {
+		var h uint64
+		if xx.ln > 31 {
+			h = mergeVs64(xx.vs[4*i : 4*i+4 : 4*i+4])
+		} else {
+			h = seed + prime64x5
+		}
+		out[i] = finalize64(h+xx.ln, xx.mem[:xx.memIdx])
+	}
+	return out
+}
+
+// ChecksumMulti64 sets out[i] to Checksum64S(in, seeds[i]) while reading in only once,
+// out must be at least as long as seeds.
+func ChecksumMulti64(in []byte, seeds []uint64, out []uint64) {
+	out = out[:len(seeds):len(seeds)]
+	if len(in) < 32 {
+		for i, seed := range seeds {
+			out[i] = Checksum64S(in, seed)
+		}
+		return
+	}
+
+	var (
+		buf [32]uint64
+		vs  []uint64
+	)
+	if len(seeds) <= len(buf)/4 {
+		vs = buf[:4*len(seeds)]
+	} else {
+		vs = make([]uint64, 4*len(seeds))
+	}
+	for i, seed := range seeds {
+		vs[4*i], vs[4*i+1], vs[4*i+2], vs[4*i+3] = resetVs64(seed)
+	}
+
+	stripes := len(in) &^ 31
+	roundsMulti(vs, in[:stripes])
+	for i := range seeds {
+		out[i] = finalize64(mergeVs64(vs[4*i:4*i+4:4*i+4])+uint64(len(in)), in[stripes:])
+	}
+}
+
+// multiBlockSize is how much input roundsMulti hands to each seed at a time,
+// small enough to stay in L1 while every seed's lanes consume it from registers.
+const multiBlockSize = 4 << 10
+
+// roundsMulti feeds every 32 byte stripe of in to each set of 4 lanes in vs, len(in) must be a multiple of 32.
+func roundsMulti(vs []uint64, in []byte) {
+	for len(in) > 0 {
+		blk := in
+		if len(blk) > multiBlockSize {
+			blk = blk[:multiBlockSize]
+		}
+		in = in[len(blk):]
+
+		j := 0
+		for ; j+8 <= len(vs); j += 8 {
+			roundsMulti2(vs[j:j+8:j+8], blk)
+		}
+		if j < len(vs) {
+			v := vs[j : j+4 : j+4]
+			v1, v2, v3, v4 := v[0], v[1], v[2], v[3]
+			for i := 0; i+32 <= len(blk); i += 32 {
+				b := blk[i : i+32 : i+32]
+				v1 = round64(v1, u64(b[0:8:8]))
+				v2 = round64(v2, u64(b[8:16:16]))
+				v3 = round64(v3, u64(b[16:24:24]))
+				v4 = round64(v4, u64(b[24:32:32]))
+			}
+			v[0], v[1], v[2], v[3] = v1, v2, v3, v4
+		}
+	}
+}
+
+// roundsMulti2 is roundsMulti for exactly 2 seeds, decoding each stripe once for both.
+func roundsMulti2(v []uint64, in []byte) {
+	v = v[:8:8]
+	a1, a2, a3, a4 := v[0], v[1], v[2], v[3]
+	b1, b2, b3, b4 := v[4], v[5], v[6], v[7]
+	for i := 0; i+32 <= len(in); i += 32 {
+		b := in[i : i+32 : i+32]
+		w1, w2, w3, w4 := u64(b[0:8:8]), u64(b[8:16:16]), u64(b[16:24:24]), u64(b[24:32:32])
+		a1, b1 = round64(a1, w1), round64(b1, w1)
+		a2, b2 = round64(a2, w2), round64(b2, w2)
+		a3, b3 = round64(a3, w3), round64(b3, w3)
+		a4, b4 = round64(a4, w4), round64(b4, w4)
+	}
+	v[0], v[1], v[2], v[3] = a1, a2, a3, a4
+	v[4], v[5], v[6], v[7] = b1, b2, b3, b4
+}
+
+func mergeVs64(v []uint64) uint64 {
+	h := rotl64_1(v[0]) + rotl64_7(v[1]) + rotl64_12(v[2]) + rotl64_18(v[3])
+	h = mergeRound64(h, v[0])
+	h = mergeRound64(h, v[1])
+	h = mergeRound64(h, v[2])
+	return mergeRound64(h, v[3])
+}
+
+// finalize64 consumes the remaining (< 32) bytes of input and applies the final mix.
+func finalize64(h uint64, in []byte) uint64 {
+	for ; len(in) >= 8; in = in[8:] {
+		h ^= round64(0, u64(in[:8:8]))
+		h = rotl64_27(h)*prime64x1 + prime64x4
+	}
+	if len(in) >= 4 {
+		h ^= uint64(u32(in[:4:4])) * prime64x1
+		h = rotl64_23(h)*prime64x2 + prime64x3
+		in = in[4:]
+	}
+	for _, b := range in {
+		h ^= uint64(b) * prime64x5
+		h = rotl64_11(h) * prime64x1
+	}
+	return mix64(h)
+}
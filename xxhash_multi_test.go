@@ -0,0 +1,84 @@
+package xxhash_test
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+func TestMulti64(t *testing.T) {
+	var (
+		rnd   = rand.New(rand.NewSource(1))
+		seeds = []uint64{0, 1, 42, 0xdeadbeefcafebabe, 7, 8, 9, 10, 11, 12}
+		data  = make([]byte, 300)
+	)
+	rnd.Read(data)
+
+	for n := 0; n <= len(data); n++ {
+		in := data[:n]
+		want := make([]uint64, len(seeds))
+		for i, seed := range seeds {
+			want[i] = xxhash.Checksum64S(in, seed)
+		}
+
+		out := make([]uint64, len(seeds))
+		xxhash.ChecksumMulti64(in, seeds, out)
+		for i := range seeds {
+			if out[i] != want[i] {
+				t.Fatalf("ChecksumMulti64: len %d, seed %d: got 0x%x; want 0x%x", n, seeds[i], out[i], want[i])
+			}
+		}
+
+		xx := xxhash.NewMulti64(seeds...)
+		for rest := in; len(rest) > 0; {
+			c := rnd.Intn(40) + 1
+			if c > len(rest) {
+				c = len(rest)
+			}
+			xx.Write(rest[:c])
+			rest = rest[c:]
+		}
+		for i, got := range xx.Sums64() {
+			if got != want[i] {
+				t.Fatalf("XXHash64Multi: len %d, seed %d: got 0x%x; want 0x%x", n, seeds[i], got, want[i])
+			}
+		}
+	}
+}
+
+func TestMulti64Reset(t *testing.T) {
+	xx := xxhash.NewMulti64(1, 2)
+	xx.WriteString("junk that is long enough to fill a whole stripe")
+	xx.Reset()
+	xx.WriteString(inS)
+	sums := xx.Sums64()
+	if sums[0] != xxhash.ChecksumString64S(inS, 1) || sums[1] != xxhash.ChecksumString64S(inS, 2) {
+		t.Fatal("Reset didn't clear the state")
+	}
+}
+
+func BenchmarkMulti64(b *testing.B) {
+	for _, n := range []int{4, 16} {
+		seeds := make([]uint64, n)
+		for i := range seeds {
+			seeds[i] = uint64(i)
+		}
+		out := make([]uint64, n)
+		b.Run("Separate/"+strconv.Itoa(n), func(b *testing.B) {
+			b.SetBytes(int64(len(in)))
+			for i := 0; i < b.N; i++ {
+				for j, seed := range seeds {
+					out[j] = xxhash.Checksum64S(in, seed)
+				}
+			}
+		})
+		b.Run("ChecksumMulti64/"+strconv.Itoa(n), func(b *testing.B) {
+			b.SetBytes(int64(len(in)))
+			for i := 0; i < b.N; i++ {
+				xxhash.ChecksumMulti64(in, seeds, out)
+			}
+		})
+	}
+}
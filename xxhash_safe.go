@@ -27,6 +27,17 @@ func (xx *XXHash64) WriteString(s string) (int, error) {
 	return xx.Write([]byte(s))
 }
 
+// stringsBytes sets bs[i] to a copy of ss[i] for as many strings as fit in bs and returns how many it set.
+func stringsBytes(bs [][]byte, ss []string) int {
+	if len(ss) > len(bs) {
+		ss = ss[:len(bs)]
+	}
+	for i, s := range ss {
+		bs[i] = []byte(s)
+	}
+	return len(ss)
+}
+
 func checksum64(in []byte, seed uint64) (h uint64) {
 	var (
 		v1, v2, v3, v4 = resetVs64(seed)
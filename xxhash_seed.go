@@ -0,0 +1,161 @@
+package xxhash
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrSecretSeed is returned when trying to serialize a Seed or a hash state that contains one.
+var ErrSecretSeed = errors.New("xxhash: refusing to serialize a secret seed")
+
+// Seed is a random secret seed, hashes computed with it can't be predicted outside the process,
+// which protects maps keyed by untrusted input from hash flooding.
+//
+// The zero Seed is invalid, use MakeSeed or DefaultSeed.
+// Seed deliberately refuses to be marshaled and doesn't print its value.
+type Seed struct {
+	s uint64
+}
+
+// MakeSeed returns a new random seed read from crypto/rand.
+func MakeSeed() Seed {
+	var b [8]byte
+	for {
+		if _, err := rand.Read(b[:]); err != nil {
+			panic("xxhash: can't read a random seed: " + err.Error())
+		}
+		if s := binary.LittleEndian.Uint64(b[:]); s != 0 {
+			return Seed{s}
+		}
+	}
+}
+
+var (
+	defaultSeed     Seed
+	defaultSeedOnce sync.Once
+)
+
+// DefaultSeed returns a random seed that is created once per process.
+func DefaultSeed() Seed {
+	defaultSeedOnce.Do(func() { defaultSeed = MakeSeed() })
+	return defaultSeed
+}
+
+func (seed Seed) value() uint64 {
+	if seed.s == 0 {
+		panic("xxhash: use of uninitialized Seed")
+	}
+	return seed.s
+}
+
+// String implements fmt.Stringer without revealing the seed.
+func (Seed) String() string { return "xxhash.Seed{...}" }
+
+// GoString implements fmt.GoStringer without revealing the seed.
+func (Seed) GoString() string { return "xxhash.Seed{...}" }
+
+// Format implements fmt.Formatter so no verb, including %d and %x, reveals the seed.
+func (Seed) Format(f fmt.State, _ rune) { io.WriteString(f, "xxhash.Seed{...}") }
+
+// MarshalBinary always returns ErrSecretSeed.
+func (Seed) MarshalBinary() ([]byte, error) { return nil, ErrSecretSeed }
+
+// MarshalText always returns ErrSecretSeed, which also stops encoding/json.
+func (Seed) MarshalText() ([]byte, error) { return nil, ErrSecretSeed }
+
+// ChecksumSeed64 returns the 64bit checksum of in with a secret seed.
+func ChecksumSeed64(in []byte, seed Seed) uint64 {
+	return Checksum64S(in, seed.value())
+}
+
+// ChecksumStringSeed64 is like ChecksumSeed64 but takes a string, without creating a copy when possible.
+func ChecksumStringSeed64(s string, seed Seed) uint64 {
+	return ChecksumString64S(s, seed.value())
+}
+
+// SeededHash64 is a streaming 64bit xxHash with a secret seed.
+// The zero value is ready to use and picks a new random seed on first use.
+//
+// Unlike XXHash64 it can't be marshaled, since its state would reveal the seed.
+type SeededHash64 struct {
+	seed Seed
+	h    XXHash64
+}
+
+// NewSeed64 returns a SeededHash64 using seed.
+func NewSeed64(seed Seed) *SeededHash64 {
+	xx := &SeededHash64{}
+	xx.SetSeed(seed)
+	return xx
+}
+
+func (xx *SeededHash64) init() {
+	if xx.seed.s == 0 {
+		xx.SetSeed(MakeSeed())
+	}
+}
+
+// SetSeed sets the seed and resets the hash.
+func (xx *SeededHash64) SetSeed(seed Seed) {
+	xx.seed = Seed{seed.value()}
+	xx.h = XXHash64{seed: seed.s}
+	xx.h.Reset()
+}
+
+// Seed returns the seed in use, initializing it if needed.
+func (xx *SeededHash64) Seed() Seed {
+	xx.init()
+	return xx.seed
+}
+
+// Reset resets the hash to its initial state, keeping the seed.
+func (xx *SeededHash64) Reset() {
+	xx.init()
+	xx.h.Reset()
+}
+
+func (xx *SeededHash64) Write(in []byte) (int, error) {
+	xx.init()
+	return xx.h.Write(in)
+}
+
+// WriteString is like Write but takes a string.
+func (xx *SeededHash64) WriteString(s string) (int, error) {
+	xx.init()
+	return xx.h.WriteString(s)
+}
+
+// Sum64 returns the checksum of the data written so far.
+func (xx *SeededHash64) Sum64() uint64 {
+	xx.init()
+	return xx.h.Sum64()
+}
+
+// Sum appends the current hash to b and returns the resulting slice.
+// It does not change the underlying hash state.
+func (xx *SeededHash64) Sum(in []byte) []byte {
+	xx.init()
+	return xx.h.Sum(in)
+}
+
+// Size returns the number of bytes Sum will return.
+func (xx *SeededHash64) Size() int { return 8 }
+
+// BlockSize returns the hash's underlying block size.
+func (xx *SeededHash64) BlockSize() int { return 32 }
+
+// String implements fmt.Stringer without revealing the seed.
+func (SeededHash64) String() string { return "xxhash.SeededHash64{...}" }
+
+// GoString implements fmt.GoStringer without revealing the seed.
+func (SeededHash64) GoString() string { return "xxhash.SeededHash64{...}" }
+
+// Format implements fmt.Formatter, fmt would otherwise print the seed from the unexported fields.
+func (SeededHash64) Format(f fmt.State, _ rune) { io.WriteString(f, "xxhash.SeededHash64{...}") }
+
+// MarshalBinary always returns ErrSecretSeed.
+func (xx *SeededHash64) MarshalBinary() ([]byte, error) { return nil, ErrSecretSeed }
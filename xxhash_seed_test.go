@@ -0,0 +1,81 @@
+package xxhash_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash"
+	"strings"
+	"testing"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+var _ hash.Hash64 = (*xxhash.SeededHash64)(nil)
+
+func TestSeed(t *testing.T) {
+	a, b := xxhash.MakeSeed(), xxhash.MakeSeed()
+	if xxhash.ChecksumStringSeed64(inS, a) == xxhash.ChecksumStringSeed64(inS, b) {
+		t.Fatal("different seeds produced the same checksum")
+	}
+	if xxhash.ChecksumSeed64(in, a) != xxhash.ChecksumStringSeed64(inS, a) {
+		t.Fatal("ChecksumSeed64 and ChecksumStringSeed64 disagree")
+	}
+	if xxhash.ChecksumStringSeed64(inS, xxhash.DefaultSeed()) != xxhash.ChecksumStringSeed64(inS, xxhash.DefaultSeed()) {
+		t.Fatal("DefaultSeed isn't stable")
+	}
+
+	h := xxhash.NewSeed64(a)
+	h.WriteString(inS[:100])
+	h.WriteString(inS[100:])
+	if h.Sum64() != xxhash.ChecksumStringSeed64(inS, a) {
+		t.Fatal("streaming and one-shot checksums disagree")
+	}
+	h.Reset()
+	h.Write(in)
+	if h.Sum64() != xxhash.ChecksumStringSeed64(inS, a) {
+		t.Fatal("Reset didn't keep the seed")
+	}
+
+	var z xxhash.SeededHash64
+	z.WriteString(inS)
+	if z.Sum64() != xxhash.ChecksumStringSeed64(inS, z.Seed()) {
+		t.Fatal("zero SeededHash64 isn't usable")
+	}
+}
+
+func TestSeedNotSerializable(t *testing.T) {
+	s := xxhash.MakeSeed()
+	if _, err := s.MarshalBinary(); err != xxhash.ErrSecretSeed {
+		t.Fatalf("expected ErrSecretSeed, got %v", err)
+	}
+	if _, err := xxhash.NewSeed64(s).MarshalBinary(); err != xxhash.ErrSecretSeed {
+		t.Fatalf("expected ErrSecretSeed, got %v", err)
+	}
+	if _, err := json.Marshal(struct{ S xxhash.Seed }{s}); err == nil {
+		t.Fatal("json.Marshal should refuse to encode a Seed")
+	}
+	h := xxhash.NewSeed64(s)
+	for _, f := range []string{"%v", "%+v", "%#v", "%s", "%d", "%x", "%X", "%o", "%q"} {
+		if out := fmt.Sprintf(f, s); out != "xxhash.Seed{...}" {
+			t.Fatalf("%s printed the seed: %s", f, out)
+		}
+		if out := fmt.Sprintf(f, struct{ S xxhash.Seed }{s}); strings.ContainsAny(out, "0123456789") {
+			t.Fatalf("%s printed the seed in a struct: %s", f, out)
+		}
+		if out := fmt.Sprintf(f, h); out != "xxhash.SeededHash64{...}" {
+			t.Fatalf("%s printed the *SeededHash64 state: %s", f, out)
+		}
+		if out := fmt.Sprintf(f, *h); out != "xxhash.SeededHash64{...}" {
+			t.Fatalf("%s printed the SeededHash64 state: %s", f, out)
+		}
+	}
+}
+
+func TestZeroSeedPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+	xxhash.ChecksumSeed64(in, xxhash.Seed{})
+}
@@ -238,3 +238,20 @@ func (xx *XXHash64) Sum64() (h uint64) {
 
 	return mix64(h)
 }
+
+// stringsBytes sets bs[i] to the bytes of ss[i], without creating a copy,
+// for as many strings as fit in bs and returns how many it set.
+func stringsBytes(bs [][]byte, ss []string) int {
+	if len(ss) > len(bs) {
+		ss = ss[:len(bs)]
+	}
+	for i, s := range ss {
+		if len(s) == 0 {
+			bs[i] = nil
+			continue
+		}
+		sh := (*reflect.StringHeader)(unsafe.Pointer(&s))
+		bs[i] = (*[maxInt32]byte)(unsafe.Pointer(sh.Data))[:len(s):len(s)]
+	}
+	return len(ss)
+}
@@ -0,0 +1,356 @@
+package xxhash
+
+import (
+	"bytes"
+	"encoding"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// HashValue encoding tags, see HashValue.
+const (
+	valueVersion = 0x01
+
+	tagNil     = 0x00
+	tagBool    = 0x01
+	tagInt     = 0x02
+	tagUint    = 0x03
+	tagFloat   = 0x04
+	tagComplex = 0x05
+	tagString  = 0x06
+	tagBytes   = 0x07
+	tagList    = 0x08
+	tagMap     = 0x09
+	tagStruct  = 0x0a
+	tagText    = 0x0b
+)
+
+// ValueOptions configures HashValue.
+type ValueOptions struct {
+	// Seed is the seed of the XXHash64 the encoding is written to.
+	Seed uint64
+}
+
+// CycleError is returned by HashValue when a value contains itself.
+type CycleError struct {
+	Type reflect.Type
+}
+
+func (e *CycleError) Error() string {
+	return "xxhash: cycle detected in value of type " + e.Type.String()
+}
+
+// UnsupportedTypeError is returned by HashValue for types it can't encode, see HashValue.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "xxhash: unsupported type " + e.Type.String()
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// HashValue returns a stable hash of v, meant for cache keys of configuration structs and similar values.
+// opts may be nil.
+//
+// v is encoded into a canonical byte stream that is fed to an XXHash64 seeded with opts.Seed.
+// The encoding doesn't depend on the Go version, the architecture or the order of map iteration,
+// integers are 8 bytes wide regardless of their Go type and all lengths are 8 byte unsigned
+// little endian integers (u64 below). The stream starts with the version byte 0x01, followed by
+// the encoding of v:
+//
+//	nil pointer or interface  0x00
+//	bool                      0x01, 0x00 or 0x01
+//	signed integer            0x02, int64 as u64
+//	unsigned integer          0x03, u64
+//	float32, float64          0x04, u64 of the float64 bits, -0 is encoded as +0 and every NaN as 0x7ff8000000000001
+//	complex64, complex128     0x05, real part then imaginary part, each as a float without its tag
+//	string                    0x06, u64 length, bytes
+//	[]byte, [N]byte           0x07, u64 length, bytes
+//	slice, array              0x08, u64 count, elements
+//	map                       0x09, u64 count, entries sorted by the bytes of their encoded keys then values, each key then value
+//	struct                    0x0a, u64 count, fields sorted by name, each name as a string then value
+//	encoding.TextMarshaler    0x0b, u64 length, text, also used for values whose pointer implements it
+//
+// Non-nil pointers and interfaces are encoded as the value they point to or hold, nil slices and maps
+// are encoded like empty ones.
+//
+// Only exported struct fields are encoded, embedded structs are regular fields named after their type.
+// The field tag `xxhash:"name"` renames a field, `xxhash:"-"` skips it, and `xxhash:",omitempty"`
+// skips it when it holds the zero value of its type.
+//
+// A value that contains itself returns a *CycleError, functions, channels, unsafe pointers and structs
+// with unexported fields but no exported ones or TextMarshaler, such as sync.Mutex, return an *UnsupportedTypeError.
+func HashValue(v interface{}, opts *ValueOptions) (uint64, error) {
+	var seed uint64
+	if opts != nil {
+		seed = opts.Seed
+	}
+
+	e := valueEncoder{visiting: make(map[visitKey]struct{})}
+	e.buf = append(e.buf, valueVersion)
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return 0, err
+	}
+	return Checksum64S(e.buf, seed), nil
+}
+
+type visitKey struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+type valueEncoder struct {
+	buf      []byte
+	visiting map[visitKey]struct{}
+}
+
+func (e *valueEncoder) tag(t byte)   { e.buf = append(e.buf, t) }
+func (e *valueEncoder) u64(x uint64) { e.buf = appendUint64(e.buf, x) }
+func (e *valueEncoder) str(t byte, s string) {
+	e.tag(t)
+	e.u64(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *valueEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.tag(tagNil)
+		return nil
+	}
+
+	if m, ok := textMarshaler(v); ok {
+		b, err := m.MarshalText()
+		if err != nil {
+			return err
+		}
+		e.str(tagText, string(b))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		e.tag(tagBool)
+		if v.Bool() {
+			e.tag(1)
+		} else {
+			e.tag(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.tag(tagInt)
+		e.u64(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.tag(tagUint)
+		e.u64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		e.tag(tagFloat)
+		e.u64(floatBits(v.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		e.tag(tagComplex)
+		e.u64(floatBits(real(c)))
+		e.u64(floatBits(imag(c)))
+	case reflect.String:
+		e.str(tagString, v.String())
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.tag(tagNil)
+			return nil
+		}
+		if v.Kind() == reflect.Interface {
+			return e.encode(v.Elem())
+		}
+		return e.visit(v, 0, func() error { return e.encode(v.Elem()) })
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.str(tagBytes, string(v.Bytes()))
+			return nil
+		}
+		if v.Len() == 0 {
+			return e.list(v)
+		}
+		return e.visit(v, v.Len(), func() error { return e.list(v) })
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.tag(tagBytes)
+			e.u64(uint64(v.Len()))
+			for i := 0; i < v.Len(); i++ {
+				e.buf = append(e.buf, byte(v.Index(i).Uint()))
+			}
+			return nil
+		}
+		return e.list(v)
+	case reflect.Map:
+		if v.Len() == 0 {
+			e.tag(tagMap)
+			e.u64(0)
+			return nil
+		}
+		return e.visit(v, 0, func() error { return e.mapEntries(v) })
+	case reflect.Struct:
+		return e.structFields(v)
+	default:
+		return &UnsupportedTypeError{v.Type()}
+	}
+	return nil
+}
+
+// textMarshaler returns v as an encoding.TextMarshaler, including types that only implement it with a pointer receiver.
+func textMarshaler(v reflect.Value) (encoding.TextMarshaler, bool) {
+	t := v.Type()
+	switch k := v.Kind(); {
+	case (k == reflect.Ptr || k == reflect.Interface) && v.IsNil():
+		return nil, false
+	case t.Implements(textMarshalerType):
+		return v.Interface().(encoding.TextMarshaler), true
+	case k == reflect.Ptr || k == reflect.Interface || !reflect.PtrTo(t).Implements(textMarshalerType):
+		return nil, false
+	case v.CanAddr():
+		return v.Addr().Interface().(encoding.TextMarshaler), true
+	}
+	p := reflect.New(t)
+	p.Elem().Set(v)
+	return p.Interface().(encoding.TextMarshaler), true
+}
+
+// visit runs fn with v marked as being encoded, and fails if v is already being encoded further up.
+func (e *valueEncoder) visit(v reflect.Value, n int, fn func() error) error {
+	k := visitKey{v.Pointer(), v.Type(), n}
+	if _, ok := e.visiting[k]; ok {
+		return &CycleError{v.Type()}
+	}
+	e.visiting[k] = struct{}{}
+	err := fn()
+	delete(e.visiting, k)
+	return err
+}
+
+func (e *valueEncoder) list(v reflect.Value) error {
+	e.tag(tagList)
+	e.u64(uint64(v.Len()))
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *valueEncoder) mapEntries(v reflect.Value) error {
+	type entry struct{ k, v []byte }
+
+	entries := make([]entry, 0, v.Len())
+	sub := valueEncoder{visiting: e.visiting}
+	it := v.MapRange()
+	for it.Next() {
+		sub.buf = nil
+		if err := sub.encode(it.Key()); err != nil {
+			return err
+		}
+		k := sub.buf
+
+		sub.buf = nil
+		if err := sub.encode(it.Value()); err != nil {
+			return err
+		}
+		entries = append(entries, entry{k, sub.buf})
+	}
+	// distinct keys can encode the same, like int(1) and int64(1) or two NaNs, so ties are broken by value.
+	sort.Slice(entries, func(i, j int) bool {
+		if c := bytes.Compare(entries[i].k, entries[j].k); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(entries[i].v, entries[j].v) < 0
+	})
+
+	e.tag(tagMap)
+	e.u64(uint64(len(entries)))
+	for _, en := range entries {
+		e.buf = append(e.buf, en.k...)
+		e.buf = append(e.buf, en.v...)
+	}
+	return nil
+}
+
+func (e *valueEncoder) structFields(v reflect.Value) error {
+	fields := structFieldsOf(v.Type())
+	if len(fields) == 0 && hasUnexported(v.Type()) {
+		// all of its state would be dropped and every value would hash the same.
+		return &UnsupportedTypeError{v.Type()}
+	}
+
+	n := 0
+	for _, f := range fields {
+		if !f.omitEmpty || !v.Field(f.index).IsZero() {
+			n++
+		}
+	}
+
+	e.tag(tagStruct)
+	e.u64(uint64(n))
+	for _, f := range fields {
+		fv := v.Field(f.index)
+		if f.omitEmpty && fv.IsZero() {
+			continue
+		}
+		e.str(tagString, f.name)
+		if err := e.encode(fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasUnexported(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath != "" {
+			return true
+		}
+	}
+	return false
+}
+
+type valueField struct {
+	index     int
+	name      string
+	omitEmpty bool
+}
+
+var valueFieldsCache sync.Map // map[reflect.Type][]valueField
+
+// structFieldsOf returns the encoded fields of t sorted by name.
+func structFieldsOf(t reflect.Type) []valueField {
+	if fs, ok := valueFieldsCache.Load(t); ok {
+		return fs.([]valueField)
+	}
+
+	fields := make([]valueField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		f := valueField{index: i, name: sf.Name}
+		if tag, ok := sf.Tag.Lookup("xxhash"); ok {
+			if tag == "-" {
+				continue
+			}
+			if i := strings.IndexByte(tag, ','); i >= 0 {
+				tag, f.omitEmpty = tag[:i], tag[i+1:] == "omitempty"
+			}
+			if tag != "" {
+				f.name = tag
+			}
+		}
+		fields = append(fields, f)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+
+	valueFieldsCache.Store(t, fields)
+	return fields
+}
@@ -0,0 +1,231 @@
+package xxhash_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/OneOfOne/xxhash"
+)
+
+type valueConfig struct {
+	Name     string          `xxhash:"name"`
+	Port     uint16          `xxhash:"port"`
+	Ratio    float64         `xxhash:"ratio,omitempty"`
+	Tags     []string        `xxhash:"tags"`
+	Limits   map[string]int  `xxhash:"limits"`
+	Next     *valueConfig    `xxhash:"next"`
+	Addr     net.IP          `xxhash:"addr"`
+	Extra    interface{}     `xxhash:"extra"`
+	Secret   string          `xxhash:"-"`
+	Labels   map[int8][]byte `xxhash:"labels"`
+	internal int
+}
+
+func mustHashValue(t *testing.T, v interface{}) uint64 {
+	t.Helper()
+	h, err := xxhash.HashValue(v, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// TestHashValueEncoding builds the documented encoding by hand.
+func TestHashValueEncoding(t *testing.T) {
+	type small struct {
+		B string `xxhash:"b"`
+		A int8   `xxhash:"a"`
+		C []bool
+		d int
+	}
+
+	var b []byte
+	u64 := func(x uint64) {
+		var a [8]byte
+		binary.LittleEndian.PutUint64(a[:], x)
+		b = append(b, a[:]...)
+	}
+	str := func(tag byte, s string) { b = append(b, tag); u64(uint64(len(s))); b = append(b, s...) }
+
+	b = append(b, 0x01) // version
+	b = append(b, 0x0a) // struct
+	u64(3)
+	str(0x06, "C")
+	b = append(b, 0x08) // list
+	u64(2)
+	b = append(b, 0x01, 0x01, 0x01, 0x00)
+	str(0x06, "a")
+	b = append(b, 0x02) // int
+	u64(math.MaxUint64)
+	str(0x06, "b")
+	str(0x06, "x")
+
+	v := small{B: "x", A: -1, C: []bool{true, false}, d: 42}
+	if got, want := mustHashValue(t, v), xxhash.Checksum64S(b, 0); got != want {
+		t.Fatalf("got 0x%x; want 0x%x", got, want)
+	}
+	if got, want := mustHashValue(t, &v), xxhash.Checksum64S(b, 0); got != want {
+		t.Fatalf("pointer: got 0x%x; want 0x%x", got, want)
+	}
+	if got, _ := xxhash.HashValue(v, &xxhash.ValueOptions{Seed: 9}); got != xxhash.Checksum64S(b, 9) {
+		t.Fatal("the seed wasn't used")
+	}
+}
+
+// TestHashValueVectors pins the output so it can't change between releases.
+func TestHashValueVectors(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want uint64
+	}{
+		{nil, 0xdd8f621dbf7f57f1},
+		{true, 0x437ddce9957b7af2},
+		{int64(-1), 0xeb04a6b14ccb4d2e},
+		{uint8(255), 0x3c279f92c2b88157},
+		{1.5, 0xc97a43269317a6fd},
+		{"hello", 0x47ca85177d61de17},
+		{[]byte("hello"), 0x1133944f4cefa532},
+		{[]int{1, 2, 3}, 0xf94b514e29f97edf},
+		{map[string]bool{"b": true, "a": false}, 0x19622109b3402914},
+		{struct {
+			A int
+			B string
+		}{1, "x"}, 0x5c5c8d4fe269b685},
+	}
+	for i, tt := range tests {
+		if got := mustHashValue(t, tt.v); got != tt.want {
+			t.Errorf("%d: %#v: got 0x%016x; want 0x%016x", i, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestHashValueCanonical(t *testing.T) {
+	a := valueConfig{
+		Name:   "svc",
+		Port:   8080,
+		Tags:   []string{"a", "b"},
+		Limits: map[string]int{"x": 1, "y": 2, "z": 3},
+		Next:   &valueConfig{Name: "child"},
+		Addr:   net.ParseIP("10.0.0.1"),
+		Extra:  int64(5),
+		Labels: map[int8][]byte{-1: []byte("neg"), 1: nil},
+	}
+	h := mustHashValue(t, a)
+
+	b := a
+	b.Secret, b.internal = "changed", 7
+	b.Limits = map[string]int{"z": 3, "y": 2, "x": 1}
+	b.Extra = 5 // int and int64 encode the same
+	b.Labels = map[int8][]byte{1: {}, -1: []byte("neg")}
+	if mustHashValue(t, b) != h {
+		t.Fatal("ignored fields, map order or integer widths changed the hash")
+	}
+
+	for _, change := range []func(c *valueConfig){
+		func(c *valueConfig) { c.Name = "other" },
+		func(c *valueConfig) { c.Ratio = 0.5 },
+		func(c *valueConfig) { c.Tags = []string{"b", "a"} },
+		func(c *valueConfig) { c.Limits = map[string]int{"x": 1} },
+		func(c *valueConfig) { c.Next = nil },
+		func(c *valueConfig) { c.Addr = net.ParseIP("10.0.0.2") },
+		func(c *valueConfig) { c.Extra = "5" },
+		func(c *valueConfig) { c.Extra = uint(5) },
+	} {
+		c := a
+		change(&c)
+		if mustHashValue(t, c) == h {
+			t.Fatalf("change didn't affect the hash: %+v", c)
+		}
+	}
+
+	// omitempty and -0 / NaN normalization.
+	if mustHashValue(t, valueConfig{Ratio: math.Copysign(0, -1)}) != mustHashValue(t, valueConfig{}) {
+		t.Fatal("-0 isn't treated as zero")
+	}
+	if mustHashValue(t, math.NaN()) != mustHashValue(t, float32(math.NaN())) {
+		t.Fatal("NaNs hash differently")
+	}
+}
+
+// distinct keys that encode the same must still hash the same on every run.
+func TestHashValueEqualKeys(t *testing.T) {
+	for _, m := range []interface{}{
+		map[interface{}]int{int(1): 1, int64(1): 2, int8(1): 3},
+		map[float64]int{math.NaN(): 1, math.NaN(): 2, math.NaN(): 3},
+	} {
+		want := mustHashValue(t, m)
+		for i := 0; i < 200; i++ {
+			if got := mustHashValue(t, m); got != want {
+				t.Fatalf("%v: got %#x; want %#x", m, got, want)
+			}
+		}
+	}
+}
+
+func TestHashValueErrors(t *testing.T) {
+	c := &valueConfig{Name: "loop"}
+	c.Next = c
+
+	var ce *xxhash.CycleError
+	if _, err := xxhash.HashValue(c, nil); !errors.As(err, &ce) {
+		t.Fatalf("expected a CycleError, got %v", err)
+	}
+
+	s := []interface{}{nil}
+	s[0] = s
+	if _, err := xxhash.HashValue(s, nil); !errors.As(err, &ce) {
+		t.Fatalf("expected a CycleError, got %v", err)
+	}
+
+	m := map[string]interface{}{}
+	m["self"] = m
+	if _, err := xxhash.HashValue(m, nil); !errors.As(err, &ce) {
+		t.Fatalf("expected a CycleError, got %v", err)
+	}
+
+	// shared pointers aren't cycles.
+	shared := &valueConfig{Name: "shared"}
+	if _, err := xxhash.HashValue([]*valueConfig{shared, shared}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var ue *xxhash.UnsupportedTypeError
+	if _, err := xxhash.HashValue(struct{ F func() }{}, nil); !errors.As(err, &ue) {
+		t.Fatalf("expected an UnsupportedTypeError, got %v", err)
+	}
+	// all of its state is unexported, so it would always hash the same.
+	if _, err := xxhash.HashValue(struct{ Mu sync.Mutex }{}, nil); !errors.As(err, &ue) {
+		t.Fatalf("expected an UnsupportedTypeError, got %v", err)
+	}
+}
+
+// big.Int only implements encoding.TextMarshaler with a pointer receiver.
+func TestHashValuePointerMarshaler(t *testing.T) {
+	type num struct{ N big.Int }
+	var a, b num
+	a.N.SetInt64(1)
+	b.N.SetInt64(999)
+
+	ha := mustHashValue(t, a)
+	if ha == mustHashValue(t, b) {
+		t.Fatal("different big.Int values hash the same")
+	}
+	if ha != mustHashValue(t, &a) {
+		t.Fatal("a value and a pointer to it hash differently")
+	}
+	if ha != mustHashValue(t, struct{ N *big.Int }{big.NewInt(1)}) {
+		t.Fatal("a big.Int field and a *big.Int field hash differently")
+	}
+}
+
+func BenchmarkHashValue(b *testing.B) {
+	c := valueConfig{Name: "svc", Port: 8080, Tags: []string{"a", "b"}, Limits: map[string]int{"x": 1, "y": 2}}
+	for i := 0; i < b.N; i++ {
+		xxhash.HashValue(c, nil)
+	}
+}